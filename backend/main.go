@@ -1,17 +1,26 @@
 package main
 
 import (
+	"context"
 	"embed"
+	"flag"
+	"fmt"
 	"io/fs"
-	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/binodta/web-k9/backend/pkg/handlers"
 	"github.com/binodta/web-k9/backend/pkg/k8s"
+	"github.com/binodta/web-k9/backend/pkg/logging"
+	"github.com/binodta/web-k9/backend/pkg/metrics"
+	"github.com/binodta/web-k9/backend/pkg/recording"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/filesystem"
-	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/gofiber/websocket/v2"
 )
 
@@ -19,25 +28,73 @@ import (
 var frontendDist embed.FS
 
 func main() {
-	app := fiber.New()
+	logLevel := flag.String("log-level", "info", "log level: debug, info, warn or error")
+	logFormat := flag.String("log-format", "text", "log format: text or json")
+	requestTimeout := flag.Duration("request-timeout", handlers.DefaultRequestTimeout, "timeout for Kubernetes calls made by REST endpoints")
+	debugImages := flag.String("debug-images", strings.Join(handlers.DefaultDebugImages, ","), "comma-separated images allowed for ephemeral debug containers")
+	nodeShellImage := flag.String("node-shell-image", handlers.DefaultNodeShellImage, "image for node shell pods; must provide nsenter")
+	nodeShellNamespace := flag.String("node-shell-namespace", handlers.DefaultNodeShellNamespace, "namespace for node shell pods")
+	nodeShellTolerations := flag.String("node-shell-tolerations", "*", "comma-separated tolerations for node shell pods (key=value:Effect, key:Effect, key, or * for all taints)")
+	recordingsDir := flag.String("recordings-dir", "", "directory for exec session recordings; recording is disabled when empty")
+	recordingUserHeader := flag.String("recordings-user-header", handlers.DefaultRecordingUserHeader, "request header identifying the user in recording metadata")
+	portForwardAddress := flag.String("port-forward-address", handlers.DefaultPortForwardAddress, "host address that port-forwards listen on")
+	flag.Parse()
+
+	logger, err := logging.New(os.Stderr, *logLevel, *logFormat)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	tolerations, err := k8s.ParseTolerations(*nodeShellTolerations)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	var recordings *recording.Store
+	if *recordingsDir != "" {
+		if recordings, err = recording.NewStore(*recordingsDir); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+
+	app := fiber.New(fiber.Config{
+		DisableStartupMessage: true,
+		// Lets file uploads be streamed into containers rather than buffered
+		StreamRequestBody: true,
+	})
 
 	// Middleware
-	app.Use(logger.New(logger.Config{
-		Format: "[${time}] ${status} - ${latency} ${method} ${path} ${error}\n",
-	}))
+	for _, mw := range logging.Middleware(logger) {
+		app.Use(mw)
+	}
+	app.Use(metrics.Middleware())
 	app.Use(cors.New(cors.Config{
 		AllowOrigins: "*",
 		AllowHeaders: "Origin, Content-Type, Accept",
 	}))
 
-	k8sManager := k8s.NewClientManager()
-	h := handlers.NewHandler(k8sManager)
+	k8sManager := k8s.NewClientManager(logger)
+	h := handlers.NewHandler(k8sManager, logger, handlers.Options{
+		RequestTimeout: *requestTimeout,
+		DebugImages:    splitList(*debugImages),
+		NodeShell: k8s.NodeShellOptions{
+			Image:       *nodeShellImage,
+			Namespace:   *nodeShellNamespace,
+			Tolerations: tolerations,
+		},
+		Recordings:          recordings,
+		RecordingUserHeader: *recordingUserHeader,
+		PortForwardAddress:  *portForwardAddress,
+	})
 
 	// API Routes
 	api := app.Group("/api")
 	api.Get("/configs", h.ListConfigs)
 	api.Post("/select-config", h.SelectConfig)
 	api.Get("/namespaces", h.ListNamespaces)
+	api.Get("/namespaces/:name/termination", h.NamespaceTermination)
 	api.Get("/resources/:type", h.ListResources)
 	api.Get("/resources/:type/:name", h.GetResource)
 	api.Delete("/resources/:type/:name", h.DeleteResource)
@@ -45,24 +102,71 @@ func main() {
 	api.Get("/cluster-info", h.GetClusterInfo)
 	api.Get("/resources/:type/:name/yaml", h.GetResourceYaml)
 	api.Put("/resources/:type/:name/yaml", h.UpdateResourceYaml)
+	api.Get("/resources/:type/:name/logs", h.DownloadLogs)
+	api.Put("/resources/:type/:name/scale", h.ScaleResource)
+	// "-" is not a valid object name, so bulk routes cannot collide with objects
+	api.Patch("/resources/:type/-/labels", h.PatchLabels)
+	api.Patch("/resources/:type/-/annotations", h.PatchAnnotations)
+	api.Patch("/resources/:type/:name/labels", h.PatchLabels)
+	api.Patch("/resources/:type/:name/annotations", h.PatchAnnotations)
+	api.Patch("/resources/:type/:name", h.PatchResource)
+	api.Get("/resources/:type/:name/rollout/history", h.RolloutHistory)
+	api.Get("/resources/:type/:name/rollout/history/diff", h.RolloutDiff)
+	api.Post("/resources/:type/:name/rollout/undo", h.RolloutUndo)
+	api.Post("/resources/:type/:name/rollout/:action", h.RolloutAction)
+	api.Post("/resources/:type/:name/finalizers/remove", h.RemoveFinalizers)
+	api.Post("/nodes/:name/cordon", h.CordonNode)
+	api.Post("/nodes/:name/uncordon", h.UncordonNode)
+	api.Patch("/nodes/taints", h.PatchTaints)
+	api.Patch("/nodes/:name/taints", h.PatchTaints)
 	api.Get("/top/pods", h.GetTopPods)
 	api.Get("/top/nodes", h.GetTopNodes)
 	api.Get("/discovery", h.GetDiscovery)
+	api.Post("/exec", h.ExecCommand)
+	api.Get("/debug/images", h.ListDebugImages)
+	api.Post("/pods/:name/debug", h.DebugPod)
+	api.Get("/pods/:name/files", h.DownloadFile)
+	api.Post("/pods/:name/files", h.UploadFiles)
+	api.Get("/recordings", h.ListRecordings)
+	api.Get("/recordings/:id", h.GetRecording)
+	api.Post("/portforwards", h.StartPortForward)
+	api.Get("/portforwards", h.ListPortForwards)
+	api.Get("/portforwards/:id", h.GetPortForward)
+	api.Delete("/portforwards/:id", h.StopPortForward)
 
 	// WebSocket Routes
 	app.Get("/ws/resources", websocket.New(h.StreamResources))
 	app.Get("/ws/logs", websocket.New(h.StreamLogs))
-	app.Get("/ws/exec", websocket.New(h.ExecShell))
+	app.Get("/ws/exec", handlers.CaptureCommand, websocket.New(h.ExecShell, websocket.Config{
+		Subprotocols: handlers.ExecSubprotocols,
+	}))
+	app.Get("/ws/attach", websocket.New(h.AttachShell, websocket.Config{
+		Subprotocols: handlers.ExecSubprotocols,
+	}))
+	app.Get("/ws/drain", websocket.New(h.DrainNode))
+	app.Get("/ws/rollout-status", websocket.New(h.StreamRolloutStatus))
+	app.Get("/ws/transfers", websocket.New(h.StreamTransfer))
+	app.Get("/ws/node-shell", websocket.New(h.NodeShell, websocket.Config{
+		Subprotocols: handlers.ExecSubprotocols,
+	}))
 
-	// Health check
+	// Service proxy through the API server
+	app.All("/proxy/:context/:namespace/:service/:port/*", h.ProxyService)
+
+	// Health checks: /health for liveness, /readyz for cluster connectivity
 	app.Get("/health", func(c *fiber.Ctx) error {
 		return c.SendString("OK")
 	})
+	app.Get("/readyz", h.Readyz)
+
+	// Prometheus metrics
+	app.Get("/metrics", metrics.Handler())
 
 	// Serve Static Files From Frontend
 	distFS, err := fs.Sub(frontendDist, "frontend/dist")
 	if err != nil {
-		log.Fatal(err)
+		logger.Error("failed to load embedded frontend", "error", err)
+		os.Exit(1)
 	}
 
 	app.Use("/", filesystem.New(filesystem.Config{
@@ -81,5 +185,40 @@ func main() {
 		return c.Send(content)
 	})
 
-	log.Fatal(app.Listen(":3030"))
+	// Stop accepting connections on SIGINT or SIGTERM, then close
+	// port-forwards and remove any node shell pods whose sessions were cut short
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		sig := <-signals
+		logger.Info("shutting down", "signal", sig.String())
+		if err := app.ShutdownWithTimeout(10 * time.Second); err != nil {
+			logger.Warn("shutdown did not complete", "error", err)
+		}
+	}()
+
+	logger.Info("starting server", "addr", ":3030")
+	if err := app.Listen(":3030"); err != nil {
+		logger.Error("server stopped", "error", err)
+		os.Exit(1)
+	}
+
+	h.PortForwards.StopAll()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := k8sManager.CleanupNodeShells(ctx); err != nil {
+		logger.Warn("failed to clean up node shell pods", "error", err)
+	}
+}
+
+// splitList parses a comma-separated flag value, ignoring empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
 }
@@ -1,8 +1,7 @@
 package handlers
 
 import (
-	"context"
-	"time"
+	"fmt"
 
 	"github.com/gofiber/fiber/v2"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -13,9 +12,12 @@ func (h *Handler) ListNamespaces(c *fiber.Ctx) error {
 	if h.K8sManager.Clientset == nil {
 		return c.Status(fiber.StatusPreconditionFailed).JSON(fiber.Map{"error": "kubeconfig not loaded"})
 	}
-	nsList, err := h.K8sManager.Clientset.CoreV1().Namespaces().List(context.Background(), metav1.ListOptions{})
+	ctx, cancel := h.requestContext(c)
+	defer cancel()
+
+	nsList, err := h.K8sManager.Clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		return h.sendError(c, err)
 	}
 
 	var namespaces []string
@@ -34,12 +36,12 @@ func (h *Handler) GetTopPods(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": "metrics server not initialized"})
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := h.requestContext(c)
 	defer cancel()
 
 	podMetrics, err := h.K8sManager.MetricsClientset.MetricsV1beta1().PodMetricses(namespace).List(ctx, metav1.ListOptions{})
 	if err != nil {
-		return c.Status(fiber.StatusGatewayTimeout).JSON(fiber.Map{"error": "failed to fetch pod metrics: " + err.Error()})
+		return h.sendError(c, fmt.Errorf("failed to fetch pod metrics: %w", err))
 	}
 
 	return c.JSON(podMetrics)
@@ -51,12 +53,12 @@ func (h *Handler) GetTopNodes(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": "metrics server not initialized"})
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := h.requestContext(c)
 	defer cancel()
 
 	nodeMetrics, err := h.K8sManager.MetricsClientset.MetricsV1beta1().NodeMetricses().List(ctx, metav1.ListOptions{})
 	if err != nil {
-		return c.Status(fiber.StatusGatewayTimeout).JSON(fiber.Map{"error": "failed to fetch node metrics: " + err.Error()})
+		return h.sendError(c, fmt.Errorf("failed to fetch node metrics: %w", err))
 	}
 
 	return c.JSON(nodeMetrics)
@@ -68,9 +70,12 @@ func (h *Handler) GetClusterInfo(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "kubeconfig not loaded"})
 	}
 
-	version, err := h.K8sManager.Clientset.Discovery().ServerVersion()
+	ctx, cancel := h.requestContext(c)
+	defer cancel()
+
+	version, err := h.K8sManager.ServerVersion(ctx)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		return h.sendError(c, err)
 	}
 
 	contextName := h.K8sManager.SelectedContext
@@ -1,8 +1,6 @@
 package handlers
 
 import (
-	"fmt"
-
 	"github.com/gofiber/fiber/v2"
 )
 
@@ -10,7 +8,7 @@ import (
 func (h *Handler) ListConfigs(c *fiber.Ctx) error {
 	configs, err := h.K8sManager.DiscoverKubeconfigs()
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		return h.sendError(c, err)
 	}
 	return c.JSON(fiber.Map{"configs": configs})
 }
@@ -25,10 +23,11 @@ func (h *Handler) SelectConfig(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "invalid body"})
 	}
 
-	fmt.Printf("DEBUG: Selecting config path=%s, context=%s\n", body.Path, body.Context)
+	logger := h.requestLogger(c)
+	logger.Info("selecting kubeconfig", "path", body.Path, "context", body.Context)
 	if err := h.K8sManager.LoadConfig(body.Path, body.Context); err != nil {
-		fmt.Printf("DEBUG: ERROR loading config: %v\n", err)
-		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		logger.Error("failed to load kubeconfig", "path", body.Path, "error", err)
+		return h.sendError(c, err)
 	}
 
 	return c.JSON(fiber.Map{
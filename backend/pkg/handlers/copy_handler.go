@@ -0,0 +1,336 @@
+package handlers
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/remotecommand"
+)
+
+// copyStderrLimit caps the tar diagnostics kept for error messages
+const copyStderrLimit = 64 << 10
+
+// DownloadFile copies a file or directory out of a container using tar over
+// exec, as kubectl cp does. A regular file is sent as-is; a directory is
+// sent as a .tar archive. Progress is published to /ws/transfers when a
+// transferId is given.
+func (h *Handler) DownloadFile(c *fiber.Ctx) error {
+	if h.K8sManager.Clientset == nil || h.K8sManager.Config == nil {
+		return c.Status(fiber.StatusPreconditionFailed).JSON(fiber.Map{"error": "kubeconfig not loaded"})
+	}
+	pod := c.Params("name")
+	namespace := c.Query("namespace", "default")
+	container := c.Query("container")
+	srcPath := path.Clean(c.Query("path"))
+	if !path.IsAbs(srcPath) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "path must be absolute"})
+	}
+
+	dir, base := path.Split(srcPath)
+	if base == "" {
+		dir, base = "/", "."
+	}
+	executor, err := h.newExecutor(namespace, pod, container, []string{"tar", "cf", "-", "-C", dir, base}, false, false)
+	if err != nil {
+		return h.sendError(c, err)
+	}
+
+	tracker := h.trackTransfer(c.Query("transferId"), "download", srcPath, 0)
+	logger := h.requestLogger(c)
+	logger.Info("downloading from container", "namespace", namespace, "pod", pod, "container", container, "path", srcPath)
+
+	// The body is written after this handler returns, so the exec must
+	// outlive the request timeout
+	streamCtx, streamCancel := context.WithCancel(c.UserContext())
+	pr, pw := io.Pipe()
+	stderr := &limitedBuffer{limit: copyStderrLimit}
+	go func() {
+		err := executor.StreamWithContext(streamCtx, remotecommand.StreamOptions{Stdout: pw, Stderr: stderr})
+		pw.CloseWithError(copyFailure(err, stderr.String(), container))
+	}()
+
+	br := bufio.NewReaderSize(&progressReader{r: pr, tracker: tracker}, 64<<10)
+	// Wait for the first tar blocks, so failures are still reported with a
+	// proper status before the response is committed
+	peek, err := br.Peek(4096)
+	if len(peek) == 0 {
+		streamCancel()
+		if err == io.EOF {
+			err = fmt.Errorf("tar produced no output for %s", srcPath)
+		}
+		tracker.finish(err)
+		return h.sendError(c, err)
+	}
+
+	hdr, err := tar.NewReader(bytes.NewReader(peek)).Next()
+	if err == io.EOF {
+		// GNU tar writes an empty archive before failing on a missing path,
+		// so wait for the exec result to report why there are no entries
+		_, err = io.Copy(io.Discard, br)
+		streamCancel()
+		if err == nil {
+			err = fmt.Errorf("tar produced no output for %s", srcPath)
+		}
+		tracker.finish(err)
+		return h.sendError(c, err)
+	}
+
+	body := &downloadBody{tracker: tracker, cancel: streamCancel, pipe: pr}
+	if err == nil && hdr.Typeflag == tar.TypeReg && path.Clean(hdr.Name) == base {
+		tr := tar.NewReader(br)
+		if _, err := tr.Next(); err != nil {
+			body.Close()
+			return h.sendError(c, err)
+		}
+		body.Reader = tr
+		c.Set(fiber.HeaderContentType, fiber.MIMEOctetStream)
+		c.Attachment(base)
+		c.Context().SetBodyStream(body, int(hdr.Size))
+		return nil
+	}
+
+	name := base
+	if name == "." {
+		name = "root"
+	}
+	body.Reader = br
+	c.Set(fiber.HeaderContentType, "application/x-tar")
+	c.Attachment(name + ".tar")
+	c.Context().SetBodyStream(body, -1)
+	return nil
+}
+
+// downloadBody is the response body of a download. Closing it stops the
+// exec and reports the transfer as finished, or interrupted if the body was
+// not read to the end.
+type downloadBody struct {
+	io.Reader
+	tracker *transferTracker
+	cancel  context.CancelFunc
+	pipe    *io.PipeReader
+	eof     bool
+	err     error
+}
+
+func (b *downloadBody) Read(p []byte) (int, error) {
+	n, err := b.Reader.Read(p)
+	if err == io.EOF {
+		b.eof = true
+	} else if err != nil {
+		b.err = err
+	}
+	return n, err
+}
+
+func (b *downloadBody) Close() error {
+	b.cancel()
+	b.pipe.Close()
+	switch {
+	case b.eof:
+		b.tracker.finish(nil)
+	case b.err != nil:
+		b.tracker.finish(b.err)
+	default:
+		b.tracker.finish(errors.New("download interrupted"))
+	}
+	return nil
+}
+
+// UploadFiles copies the files of a multipart form into a directory of a
+// container using tar over exec, as kubectl cp does. File names may contain
+// relative paths, as sent for directory uploads, and missing directories are
+// created. The request body is streamed, and progress is published to
+// /ws/transfers when a transferId is given.
+func (h *Handler) UploadFiles(c *fiber.Ctx) error {
+	if h.K8sManager.Clientset == nil || h.K8sManager.Config == nil {
+		return c.Status(fiber.StatusPreconditionFailed).JSON(fiber.Map{"error": "kubeconfig not loaded"})
+	}
+	pod := c.Params("name")
+	namespace := c.Query("namespace", "default")
+	container := c.Query("container")
+	destPath := path.Clean(c.Query("path"))
+	if !path.IsAbs(destPath) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "path must be absolute"})
+	}
+	boundary := string(c.Request().Header.MultipartFormBoundary())
+	if boundary == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "expected a multipart/form-data body"})
+	}
+
+	executor, err := h.newExecutor(namespace, pod, container, []string{"tar", "xmf", "-", "-C", destPath}, true, false)
+	if err != nil {
+		return h.sendError(c, err)
+	}
+
+	var body io.Reader = c.Context().RequestBodyStream()
+	if body == nil {
+		body = bytes.NewReader(c.Body())
+	}
+	tracker := h.trackTransfer(c.Query("transferId"), "upload", destPath, int64(c.Request().Header.ContentLength()))
+	logger := h.requestLogger(c)
+	logger.Info("uploading to container", "namespace", namespace, "pod", pod, "container", container, "path", destPath)
+
+	ctx, cancel := context.WithCancel(c.UserContext())
+	defer cancel()
+
+	pr, pw := io.Pipe()
+	stdout := &limitedBuffer{limit: copyStderrLimit}
+	stderr := &limitedBuffer{limit: copyStderrLimit}
+	execDone := make(chan error, 1)
+	go func() {
+		err := executor.StreamWithContext(ctx, remotecommand.StreamOptions{Stdin: pr, Stdout: stdout, Stderr: stderr})
+		// Unblock the archive writer if tar exited early
+		pr.CloseWithError(io.ErrClosedPipe)
+		execDone <- err
+	}()
+
+	mr := multipart.NewReader(&progressReader{r: body, tracker: tracker}, boundary)
+	files, size, writeErr := writeUploadArchive(pw, mr, tracker)
+	pw.CloseWithError(writeErr)
+	execErr := <-execDone
+
+	if err := copyFailure(execErr, stderr.String(), container); err != nil {
+		tracker.finish(err)
+		return h.sendError(c, err)
+	}
+	if writeErr != nil {
+		tracker.finish(writeErr)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": writeErr.Error()})
+	}
+	tracker.finish(nil)
+	logger.Info("uploaded to container", "path", destPath, "files", len(files), "bytes", size)
+	return c.JSON(fiber.Map{"path": destPath, "files": files, "bytes": size})
+}
+
+// writeUploadArchive writes every file part of a multipart form to w as a
+// tar archive. Tar headers need the entry size up front, so each part is
+// spooled to a temporary file first.
+func writeUploadArchive(w io.Writer, mr *multipart.Reader, tracker *transferTracker) ([]string, int64, error) {
+	tw := tar.NewWriter(w)
+	files := []string{}
+	var total int64
+
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return files, total, fmt.Errorf("failed to read upload: %w", err)
+		}
+		name, err := uploadFileName(part)
+		if err != nil {
+			part.Close()
+			return files, total, err
+		}
+		if name == "" {
+			part.Close()
+			continue
+		}
+		tracker.setFile(name)
+
+		size, err := writeUploadEntry(tw, name, part)
+		part.Close()
+		if err != nil {
+			return files, total, err
+		}
+		files = append(files, name)
+		total += size
+	}
+	if len(files) == 0 {
+		return files, total, errors.New("no files in upload")
+	}
+	return files, total, tw.Close()
+}
+
+func writeUploadEntry(tw *tar.Writer, name string, r io.Reader) (int64, error) {
+	spool, err := os.CreateTemp("", "webk9-upload-*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	size, err := io.Copy(spool, r)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	hdr := &tar.Header{Name: name, Mode: 0o644, Size: size, ModTime: time.Now()}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return 0, err
+	}
+	_, err = io.Copy(tw, spool)
+	return size, err
+}
+
+// uploadFileName returns the relative path of a file part, or "" for other
+// form fields. Part.FileName drops directories, so the Content-Disposition
+// header is parsed directly to keep the paths of directory uploads.
+func uploadFileName(part *multipart.Part) (string, error) {
+	_, params, err := mime.ParseMediaType(part.Header.Get("Content-Disposition"))
+	if err != nil {
+		return "", nil
+	}
+	filename := strings.ReplaceAll(params["filename"], "\\", "/")
+	if filename == "" {
+		return "", nil
+	}
+	name := path.Clean("/" + filename)[1:]
+	if name == "" || name == "." {
+		return "", fmt.Errorf("invalid file name %q", filename)
+	}
+	return name, nil
+}
+
+// copyFailure explains why a tar exec failed, including tar's own message
+func copyFailure(err error, stderr string, container string) error {
+	if err == nil {
+		return nil
+	}
+	if isCommandNotFound(err) {
+		target := "the container"
+		if container != "" {
+			target = fmt.Sprintf("container %q", container)
+		}
+		return copyStatusError(fiber.StatusUnprocessableEntity, metav1.StatusReasonInvalid,
+			fmt.Sprintf("tar is not available in %s; copying files requires tar in the image", target))
+	}
+	stderr = strings.TrimSpace(stderr)
+	if stderr == "" {
+		return err
+	}
+	if strings.Contains(stderr, "No such file or directory") || strings.Contains(stderr, "Cannot change directory") {
+		return copyStatusError(fiber.StatusNotFound, metav1.StatusReasonNotFound, stderr)
+	}
+	if strings.Contains(stderr, "Permission denied") || strings.Contains(stderr, "Read-only file system") {
+		return copyStatusError(fiber.StatusForbidden, metav1.StatusReasonForbidden, stderr)
+	}
+	return fmt.Errorf("%w: %s", err, stderr)
+}
+
+func copyStatusError(code int, reason metav1.StatusReason, message string) error {
+	return &apierrors.StatusError{ErrStatus: metav1.Status{
+		Status:  metav1.StatusFailure,
+		Code:    int32(code),
+		Reason:  reason,
+		Message: message,
+	}}
+}
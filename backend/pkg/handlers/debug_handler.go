@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"context"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// DefaultDebugImages are offered for debug containers when none are configured
+var DefaultDebugImages = []string{"busybox:1.36", "nicolaka/netshoot:latest"}
+
+// debugStartTimeout bounds how long a debug container may take to start,
+// including pulling its image
+const debugStartTimeout = 2 * time.Minute
+
+// ListDebugImages returns the images that may be used for debug containers
+func (h *Handler) ListDebugImages(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{"images": h.Options.DebugImages})
+}
+
+// DebugPod adds an ephemeral debug container to a pod, sharing the process
+// namespace of the target container, and waits for it to run. The response
+// names the container and the /ws/attach URL that opens a terminal in it.
+func (h *Handler) DebugPod(c *fiber.Ctx) error {
+	if h.K8sManager.Clientset == nil {
+		return c.Status(fiber.StatusPreconditionFailed).JSON(fiber.Map{"error": "kubeconfig not loaded"})
+	}
+	pod := c.Params("name")
+	namespace := c.Query("namespace", "default")
+
+	var body struct {
+		Container string `json:"container"`
+		Image     string `json:"image"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid body"})
+	}
+	if body.Image == "" && len(h.Options.DebugImages) > 0 {
+		body.Image = h.Options.DebugImages[0]
+	}
+	if !slices.Contains(h.Options.DebugImages, body.Image) {
+		return c.Status(400).JSON(fiber.Map{"error": "image is not in the list of allowed debug images"})
+	}
+
+	ctx, cancel := context.WithTimeout(c.UserContext(), debugStartTimeout)
+	defer cancel()
+
+	logger := h.requestLogger(c)
+	name, err := h.K8sManager.AddDebugContainer(ctx, namespace, pod, body.Container, body.Image)
+	if err != nil {
+		return h.sendError(c, err)
+	}
+	logger.Info("added debug container", "namespace", namespace, "pod", pod, "container", name, "target", body.Container, "image", body.Image)
+
+	if err := h.K8sManager.WaitForEphemeralContainer(ctx, namespace, pod, name); err != nil {
+		return h.sendError(c, err)
+	}
+
+	query := url.Values{"namespace": {namespace}, "pod": {pod}, "container": {name}}
+	return c.JSON(fiber.Map{
+		"container": name,
+		"image":     body.Image,
+		"attach":    "/ws/attach?" + query.Encode(),
+	})
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/websocket/v2"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// errorResponse is the JSON body returned for failed requests. Error is kept
+// for clients that only display a message; the remaining fields mirror the
+// metav1.Status returned by the API server.
+type errorResponse struct {
+	Error             string        `json:"error"`
+	Code              int           `json:"code"`
+	Reason            string        `json:"reason,omitempty"`
+	Message           string        `json:"message,omitempty"`
+	Details           *errorDetails `json:"details,omitempty"`
+	RetryAfterSeconds int           `json:"retryAfterSeconds,omitempty"`
+}
+
+type errorDetails struct {
+	Name   string       `json:"name,omitempty"`
+	Group  string       `json:"group,omitempty"`
+	Kind   string       `json:"kind,omitempty"`
+	Causes []errorCause `json:"causes,omitempty"`
+}
+
+type errorCause struct {
+	Type    string `json:"type,omitempty"`
+	Message string `json:"message,omitempty"`
+	Field   string `json:"field,omitempty"`
+}
+
+// translateError converts an error from a Kubernetes call into an HTTP status
+// and response body. API server statuses are passed through with their reason
+// and details, and deadlines become 504.
+func translateError(err error) errorResponse {
+	resp := errorResponse{
+		Error:   err.Error(),
+		Code:    fiber.StatusInternalServerError,
+		Message: err.Error(),
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		resp.Code = fiber.StatusGatewayTimeout
+		resp.Reason = string(metav1.StatusReasonTimeout)
+		return resp
+	}
+
+	var apiStatus apierrors.APIStatus
+	if !errors.As(err, &apiStatus) {
+		return resp
+	}
+
+	status := apiStatus.Status()
+	if status.Code != 0 {
+		resp.Code = int(status.Code)
+	}
+	resp.Reason = string(status.Reason)
+	if status.Message != "" {
+		resp.Message = status.Message
+	}
+	if d := status.Details; d != nil {
+		resp.Details = &errorDetails{Name: d.Name, Group: d.Group, Kind: d.Kind}
+		for _, cause := range d.Causes {
+			resp.Details.Causes = append(resp.Details.Causes, errorCause{
+				Type:    string(cause.Type),
+				Message: cause.Message,
+				Field:   cause.Field,
+			})
+		}
+	}
+	if delay, ok := apierrors.SuggestsClientDelay(err); ok {
+		resp.RetryAfterSeconds = delay
+	}
+	return resp
+}
+
+// sendError writes err as a structured JSON error response with a matching
+// status code, setting Retry-After when the API server asked clients to back off
+func (h *Handler) sendError(c *fiber.Ctx, err error) error {
+	resp := translateError(err)
+	if resp.RetryAfterSeconds > 0 {
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(resp.RetryAfterSeconds))
+	}
+	return c.Status(resp.Code).JSON(resp)
+}
+
+// writeWSError sends err to a WebSocket client using the same body as sendError
+func writeWSError(c *websocket.Conn, err error) error {
+	return c.WriteJSON(translateError(err))
+}
+
+// sendResolveError reports a failure to resolve a resource type: 404 for
+// types the cluster does not serve, otherwise as sendError
+func (h *Handler) sendResolveError(c *fiber.Ctx, err error) error {
+	if meta.IsNoMatchError(err) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	}
+	return h.sendError(c, err)
+}
@@ -0,0 +1,357 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/binodta/web-k9/backend/pkg/metrics"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/websocket/v2"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/remotecommand"
+	"k8s.io/client-go/util/exec"
+)
+
+// commandLocalsKey holds the repeated command query parameters, captured
+// before the WebSocket upgrade because websocket.Conn keeps only one value
+const commandLocalsKey = "execCommand"
+
+// shellFallbacks are tried in order when no command is given
+var shellFallbacks = []string{"bash", "sh", "ash"}
+
+// execOutputLimit caps the stdout and stderr captured by one-shot execs
+const execOutputLimit = 1 << 20
+
+// execMessage is a control message sent by exec clients:
+//
+//	{"type": "stdin", "data": "ls\r"}
+//	{"type": "resize", "cols": 120, "rows": 40}
+//
+// Messages that are not control messages are passed to stdin unchanged.
+type execMessage struct {
+	Type string `json:"type"`
+	Data string `json:"data,omitempty"`
+	Cols uint16 `json:"cols,omitempty"`
+	Rows uint16 `json:"rows,omitempty"`
+}
+
+// CaptureCommand stores every command query parameter for ExecShell, so
+// /ws/exec?command=sh&command=-c&command=ls%20-la runs `sh -c 'ls -la'`
+func CaptureCommand(c *fiber.Ctx) error {
+	var command []string
+	for _, arg := range c.Context().QueryArgs().PeekMulti("command") {
+		command = append(command, string(arg))
+	}
+	c.Locals(commandLocalsKey, command)
+	return c.Next()
+}
+
+// newExecutor prepares an exec of command in a pod's container
+func (h *Handler) newExecutor(namespace string, pod string, container string, command []string, stdin bool, tty bool) (remotecommand.Executor, error) {
+	req := h.K8sManager.Clientset.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Name(pod).
+		Namespace(namespace).
+		SubResource("exec").
+		VersionedParams(&v1.PodExecOptions{
+			Container: container,
+			Command:   command,
+			Stdin:     stdin,
+			Stdout:    true,
+			Stderr:    !tty,
+			TTY:       tty,
+		}, scheme.ParameterCodec)
+
+	return h.streamExecutor(req.URL())
+}
+
+// newAttachExecutor prepares an attach to the main process of a pod's container
+func (h *Handler) newAttachExecutor(namespace string, pod string, container string, stdin bool, tty bool) (remotecommand.Executor, error) {
+	req := h.K8sManager.Clientset.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Name(pod).
+		Namespace(namespace).
+		SubResource("attach").
+		VersionedParams(&v1.PodAttachOptions{
+			Container: container,
+			Stdin:     stdin,
+			Stdout:    true,
+			Stderr:    !tty,
+			TTY:       tty,
+		}, scheme.ParameterCodec)
+
+	return h.streamExecutor(req.URL())
+}
+
+// streamExecutor uses the WebSocket streaming protocol, falling back to SPDY
+// when the API server or a proxy in between cannot upgrade to WebSockets,
+// as kubectl does
+func (h *Handler) streamExecutor(u *url.URL) (remotecommand.Executor, error) {
+	websocketExec, err := remotecommand.NewWebSocketExecutor(h.K8sManager.Config, "GET", u.String())
+	if err != nil {
+		return nil, err
+	}
+	spdyExec, err := remotecommand.NewSPDYExecutor(h.K8sManager.Config, "POST", u)
+	if err != nil {
+		return nil, err
+	}
+	return remotecommand.NewFallbackExecutor(websocketExec, spdyExec, func(err error) bool {
+		return httpstream.IsUpgradeFailure(err) || httpstream.IsHTTPSProxyError(err)
+	})
+}
+
+// ExecShell handles WebSocket connections for interactive pod shell.
+// Clients that negotiate a channel subprotocol exchange binary channel
+// frames and receive the exit status as the final frame. Other clients send
+// keystrokes either as raw messages or as execMessage control messages,
+// which also carry terminal resize events. Without a command the first
+// available shell of bash, sh and ash is started. tty=false separates stderr
+// from stdout. Sessions are recorded when a recordings store is configured.
+func (h *Handler) ExecShell(c *websocket.Conn) {
+	defer metrics.TrackWebSocket("exec")()
+	if h.K8sManager.Clientset == nil || h.K8sManager.Config == nil {
+		c.WriteJSON(fiber.Map{"error": "kubeconfig not loaded"})
+		return
+	}
+	namespace := c.Query("namespace", "default")
+	pod := c.Query("pod")
+	container := c.Query("container")
+
+	if pod == "" {
+		c.WriteJSON(fiber.Map{"error": "pod name is required"})
+		return
+	}
+	tty, err := parseBoolQuery(c, "tty", true)
+	if err != nil {
+		c.WriteJSON(fiber.Map{"error": err.Error()})
+		return
+	}
+
+	commands := [][]string{}
+	if command, _ := c.Locals(commandLocalsKey).([]string); len(command) > 0 {
+		commands = append(commands, command)
+	} else if command := c.Query("command"); command != "" {
+		commands = append(commands, []string{command})
+	} else {
+		for _, shell := range shellFallbacks {
+			commands = append(commands, []string{shell})
+		}
+	}
+
+	baseCtx, logger := h.wsContext(c)
+	ctx, cancel := context.WithCancel(baseCtx)
+	defer cancel()
+
+	rec := h.startRecording(c, namespace, pod, container, commands[0])
+	defer h.stopRecording(rec, logger)
+
+	// Bridges the WebSocket and the exec streams across fallback attempts
+	session := newExecSession(ctx, c, cancel, rec)
+
+	var started []string
+	for i, command := range commands {
+		logger.Info("starting exec session", "namespace", namespace, "pod", pod, "container", container, "command", command[0])
+
+		var executor remotecommand.Executor
+		executor, err = h.newExecutor(namespace, pod, container, command, true, tty)
+		if err != nil {
+			logger.Warn("failed to create executor", "error", err)
+			if session.binary {
+				session.finish(err)
+			} else {
+				c.WriteMessage(websocket.TextMessage, []byte("\x1b[1;31mError: "+err.Error()+"\x1b[0m\n"))
+			}
+			return
+		}
+
+		attemptCtx, attemptCancel := context.WithCancel(ctx)
+		started = command
+		session.setCommand(command)
+		streams := session.streams(attemptCtx)
+		err = executor.StreamWithContext(attemptCtx, streams.options(tty))
+		attemptCancel()
+
+		// Only fall back while nothing has reached the terminal, so a real
+		// shell exiting with 127 is not mistaken for a missing one
+		if err == nil || i == len(commands)-1 || session.wrote.Load() || !isCommandNotFound(err) {
+			break
+		}
+		logger.Debug("shell not available, trying next", "command", command[0], "error", err)
+	}
+	// Commands that exit without output are recorded as the last one tried
+	rec.Begin(started)
+
+	if ctx.Err() != nil {
+		return
+	}
+	if err != nil {
+		logger.Info("exec session ended with error", "error", err)
+	}
+	session.finish(err)
+}
+
+// AttachShell handles WebSocket connections attached to the main process of
+// a running container, using the same protocols as ExecShell. Stdin and TTY
+// follow the container spec, as with kubectl attach.
+func (h *Handler) AttachShell(c *websocket.Conn) {
+	defer metrics.TrackWebSocket("attach")()
+	if h.K8sManager.Clientset == nil || h.K8sManager.Config == nil {
+		c.WriteJSON(fiber.Map{"error": "kubeconfig not loaded"})
+		return
+	}
+	namespace := c.Query("namespace", "default")
+	podName := c.Query("pod")
+	container := c.Query("container")
+
+	if podName == "" {
+		c.WriteJSON(fiber.Map{"error": "pod name is required"})
+		return
+	}
+
+	baseCtx, logger := h.wsContext(c)
+	ctx, cancel := context.WithCancel(baseCtx)
+	defer cancel()
+
+	session := newExecSession(ctx, c, cancel, nil)
+
+	pod, err := h.K8sManager.Clientset.CoreV1().Pods(namespace).Get(ctx, podName, metav1.GetOptions{})
+	if err != nil {
+		session.finish(err)
+		return
+	}
+	spec, ok := findContainer(pod, container)
+	if !ok {
+		session.finish(fmt.Errorf("container %q not found in pod %s", container, podName))
+		return
+	}
+
+	logger.Info("attaching to container", "namespace", namespace, "pod", podName, "container", spec.Name)
+	executor, err := h.newAttachExecutor(namespace, podName, spec.Name, spec.Stdin, spec.TTY)
+	if err != nil {
+		session.finish(err)
+		return
+	}
+
+	opts := session.streams(ctx).options(spec.TTY)
+	if !spec.Stdin {
+		opts.Stdin = nil
+		session.disableStdin()
+	}
+	err = executor.StreamWithContext(ctx, opts)
+	if ctx.Err() != nil {
+		return
+	}
+	if err != nil {
+		logger.Info("attach session ended with error", "error", err)
+	}
+	session.finish(err)
+}
+
+// findContainer returns the named container of a pod, or its first container
+// when name is empty. Ephemeral containers are included so debug containers
+// can be attached to.
+func findContainer(pod *v1.Pod, name string) (v1.Container, bool) {
+	if name == "" && len(pod.Spec.Containers) > 0 {
+		return pod.Spec.Containers[0], true
+	}
+	for _, container := range append(pod.Spec.InitContainers, pod.Spec.Containers...) {
+		if container.Name == name {
+			return container, true
+		}
+	}
+	for _, ephemeral := range pod.Spec.EphemeralContainers {
+		if ephemeral.Name == name {
+			return v1.Container(ephemeral.EphemeralContainerCommon), true
+		}
+	}
+	return v1.Container{}, false
+}
+
+// isCommandNotFound reports whether an exec failed because the executable
+// does not exist in the container
+func isCommandNotFound(err error) bool {
+	var exitErr exec.CodeExitError
+	if errors.As(err, &exitErr) && (exitErr.Code == 126 || exitErr.Code == 127) {
+		return true
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "executable file not found") || strings.Contains(msg, "no such file or directory")
+}
+
+// ExecCommand runs a command to completion without a TTY and returns its
+// output and exit code. The request body is
+//
+//	{"namespace": "default", "pod": "web-0", "container": "app", "command": ["cat", "/etc/hosts"]}
+func (h *Handler) ExecCommand(c *fiber.Ctx) error {
+	if h.K8sManager.Clientset == nil || h.K8sManager.Config == nil {
+		return c.Status(fiber.StatusPreconditionFailed).JSON(fiber.Map{"error": "kubeconfig not loaded"})
+	}
+	var body struct {
+		Namespace string   `json:"namespace"`
+		Pod       string   `json:"pod"`
+		Container string   `json:"container"`
+		Command   []string `json:"command"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid body"})
+	}
+	if body.Pod == "" || len(body.Command) == 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "pod and command are required"})
+	}
+	if body.Namespace == "" {
+		body.Namespace = "default"
+	}
+
+	ctx, cancel := h.requestContext(c)
+	defer cancel()
+
+	executor, err := h.newExecutor(body.Namespace, body.Pod, body.Container, body.Command, false, false)
+	if err != nil {
+		return h.sendError(c, err)
+	}
+
+	stdout := &limitedBuffer{limit: execOutputLimit}
+	stderr := &limitedBuffer{limit: execOutputLimit}
+	err = executor.StreamWithContext(ctx, remotecommand.StreamOptions{
+		Stdout: stdout,
+		Stderr: stderr,
+	})
+
+	exitCode := 0
+	var exitErr exec.CodeExitError
+	if errors.As(err, &exitErr) {
+		exitCode = exitErr.Code
+	} else if err != nil {
+		return h.sendError(c, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"stdout":    stdout.String(),
+		"stderr":    stderr.String(),
+		"exitCode":  exitCode,
+		"truncated": stdout.truncated || stderr.truncated,
+	})
+}
+
+// limitedBuffer keeps the first limit bytes written and discards the rest
+type limitedBuffer struct {
+	bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.Len(); len(p) > room {
+		b.truncated = true
+		b.Buffer.Write(p[:max(room, 0)])
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
@@ -0,0 +1,349 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/binodta/web-k9/backend/pkg/recording"
+	"github.com/gofiber/websocket/v2"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/remotecommand"
+	"k8s.io/client-go/util/exec"
+)
+
+// Channel protocol subprotocols. Clients that negotiate one of these get
+// binary frames whose first byte is the channel, as in the Kubernetes
+// streaming protocols. Other clients get the text protocol of execMessage.
+const (
+	channelProtocolV5 = "v5.channel.k8s.io"
+	channelProtocolV4 = "v4.channel.k8s.io"
+)
+
+// ExecSubprotocols are the WebSocket subprotocols accepted by exec endpoints
+var ExecSubprotocols = []string{channelProtocolV5, channelProtocolV4}
+
+// Channels of the binary exec protocol
+const (
+	stdinChannel  byte = 0
+	stdoutChannel byte = 1
+	stderrChannel byte = 2
+	// errorChannel carries the final metav1.Status, including the exit code
+	errorChannel  byte = 3
+	resizeChannel byte = 4
+	// closeChannel is followed by the channel the client will not write to again
+	closeChannel byte = 255
+)
+
+// resizeMessage is the payload of a resize channel frame
+type resizeMessage struct {
+	Width  uint16 `json:"Width"`
+	Height uint16 `json:"Height"`
+}
+
+// execSession owns the WebSocket of an exec session. A single goroutine reads
+// client messages, applying resize events and queueing stdin, so several
+// exec attempts can be made over the same connection. When rec is set,
+// terminal output, stdin and resizes are recorded, and the recording begins
+// with the command of the attempt that first produces output.
+type execSession struct {
+	conn    *websocket.Conn
+	binary  bool
+	rec     *recording.Recording
+	command []string
+	input   chan []byte
+	// noStdin is closed when the remote process takes no stdin, so client
+	// input is dropped rather than blocking the reader
+	noStdin chan struct{}
+	sizes   chan remotecommand.TerminalSize
+	writeMu sync.Mutex
+	wrote   atomic.Bool
+
+	sizeMu   sync.Mutex
+	lastSize remotecommand.TerminalSize
+}
+
+func newExecSession(ctx context.Context, c *websocket.Conn, cancel context.CancelFunc, rec *recording.Recording) *execSession {
+	s := &execSession{
+		conn:    c,
+		binary:  c.Subprotocol() != "",
+		rec:     rec,
+		input:   make(chan []byte),
+		noStdin: make(chan struct{}),
+		sizes:   make(chan remotecommand.TerminalSize, 1),
+	}
+
+	// Hold the underlying connection: c itself is recycled once the handler returns
+	conn := c.Conn
+	go func() {
+		stdinOpen := true
+		defer func() {
+			if stdinOpen {
+				close(s.input)
+			}
+		}()
+		for {
+			_, msg, err := conn.ReadMessage()
+			if err != nil {
+				// The client went away, so tear down the remote process as well
+				cancel()
+				return
+			}
+
+			var data []byte
+			if s.binary {
+				var closeStdin bool
+				data, closeStdin = s.handleFrame(msg)
+				if closeStdin && stdinOpen {
+					stdinOpen = false
+					close(s.input)
+				}
+			} else {
+				data = s.handleMessage(msg)
+			}
+
+			if len(data) > 0 && stdinOpen {
+				s.rec.Input(data)
+				select {
+				case s.input <- data:
+				case <-s.noStdin:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return s
+}
+
+// disableStdin drops client input from now on, for processes without stdin.
+// The reader keeps running so resizes and the client closing are still seen.
+func (s *execSession) disableStdin() {
+	close(s.noStdin)
+}
+
+// handleMessage applies text protocol control messages and returns any stdin data
+func (s *execSession) handleMessage(msg []byte) []byte {
+	if len(msg) == 0 || msg[0] != '{' {
+		return msg
+	}
+	var ctrl execMessage
+	if err := json.Unmarshal(msg, &ctrl); err != nil {
+		return msg
+	}
+	switch ctrl.Type {
+	case "stdin":
+		return []byte(ctrl.Data)
+	case "resize":
+		if ctrl.Cols > 0 && ctrl.Rows > 0 {
+			s.resize(remotecommand.TerminalSize{Width: ctrl.Cols, Height: ctrl.Rows})
+		}
+		return nil
+	}
+	return msg
+}
+
+// handleFrame applies a binary protocol frame and returns any stdin data,
+// and whether the client has closed stdin
+func (s *execSession) handleFrame(msg []byte) (data []byte, closeStdin bool) {
+	if len(msg) == 0 {
+		return nil, false
+	}
+	switch msg[0] {
+	case stdinChannel:
+		return msg[1:], false
+	case resizeChannel:
+		var size resizeMessage
+		if err := json.Unmarshal(msg[1:], &size); err == nil && size.Width > 0 && size.Height > 0 {
+			s.resize(remotecommand.TerminalSize{Width: size.Width, Height: size.Height})
+		}
+	case closeChannel:
+		return nil, len(msg) > 1 && msg[1] == stdinChannel
+	}
+	return nil, false
+}
+
+// resize queues a terminal size, replacing one that has not been applied yet
+func (s *execSession) resize(size remotecommand.TerminalSize) {
+	s.sizeMu.Lock()
+	s.lastSize = size
+	s.sizeMu.Unlock()
+	s.rec.Resize(size.Width, size.Height)
+
+	select {
+	case s.sizes <- size:
+	default:
+		select {
+		case <-s.sizes:
+		default:
+		}
+		s.sizes <- size
+	}
+}
+
+// setCommand records which command the following output belongs to
+func (s *execSession) setCommand(command []string) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	s.command = command
+}
+
+// write sends output to the client, framed for channel in the binary protocol
+func (s *execSession) write(channel byte, p []byte) (int, error) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	if channel == stdoutChannel || channel == stderrChannel {
+		s.rec.Begin(s.command)
+		s.rec.Output(p)
+	}
+
+	var err error
+	if s.binary {
+		frame := make([]byte, len(p)+1)
+		frame[0] = channel
+		copy(frame[1:], p)
+		err = s.conn.WriteMessage(websocket.BinaryMessage, frame)
+	} else {
+		err = s.conn.WriteMessage(websocket.TextMessage, p)
+	}
+	if err != nil {
+		return 0, err
+	}
+	s.wrote.Store(true)
+	return len(p), nil
+}
+
+// finish reports how the command ended. Binary clients receive a metav1.Status
+// on the error channel; text clients only receive errors, as JSON.
+func (s *execSession) finish(err error) {
+	if !s.binary {
+		if err != nil {
+			s.writeMu.Lock()
+			defer s.writeMu.Unlock()
+			writeWSError(s.conn, err)
+		}
+		return
+	}
+
+	status, _ := json.Marshal(exitStatus(err))
+	s.write(errorChannel, status)
+}
+
+// exitStatus describes the outcome of a command the way the API server does
+// on its error channel, with non-zero exit codes as an ExitCode cause
+func exitStatus(err error) *metav1.Status {
+	status := &metav1.Status{
+		TypeMeta: metav1.TypeMeta{Kind: "Status", APIVersion: "v1"},
+		Status:   metav1.StatusSuccess,
+	}
+	if err == nil {
+		return status
+	}
+
+	status.Status = metav1.StatusFailure
+	status.Message = err.Error()
+
+	var exitErr exec.CodeExitError
+	var apiStatus apierrors.APIStatus
+	switch {
+	case errors.As(err, &exitErr):
+		status.Reason = "NonZeroExitCode"
+		status.Details = &metav1.StatusDetails{
+			Causes: []metav1.StatusCause{{
+				Type:    "ExitCode",
+				Message: strconv.Itoa(exitErr.Code),
+			}},
+		}
+	case errors.As(err, &apiStatus):
+		s := apiStatus.Status()
+		status.Code = s.Code
+		status.Reason = s.Reason
+		status.Details = s.Details
+	}
+	return status
+}
+
+// streams returns the stdin, stdout, stderr and terminal size queue of one
+// exec attempt. Reads end when ctx is done so that a finished attempt does
+// not swallow input meant for the next one.
+func (s *execSession) streams(ctx context.Context) *execStreams {
+	// A new attempt starts at the default size, so replay the client's
+	s.sizeMu.Lock()
+	last := s.lastSize
+	s.sizeMu.Unlock()
+	if last.Width > 0 {
+		s.resize(last)
+	}
+	return &execStreams{
+		session: s,
+		done:    ctx.Done(),
+		stdout:  channelWriter{session: s, channel: stdoutChannel},
+		stderr:  channelWriter{session: s, channel: stderrChannel},
+	}
+}
+
+type execStreams struct {
+	session  *execSession
+	done     <-chan struct{}
+	stdout   channelWriter
+	stderr   channelWriter
+	leftover []byte
+}
+
+func (e *execStreams) Read(p []byte) (int, error) {
+	if len(e.leftover) == 0 {
+		select {
+		case data, ok := <-e.session.input:
+			if !ok {
+				return 0, io.EOF
+			}
+			e.leftover = data
+		case <-e.done:
+			return 0, io.EOF
+		}
+	}
+	n := copy(p, e.leftover)
+	e.leftover = e.leftover[n:]
+	return n, nil
+}
+
+// Next implements remotecommand.TerminalSizeQueue
+func (e *execStreams) Next() *remotecommand.TerminalSize {
+	select {
+	case size := <-e.session.sizes:
+		return &size
+	case <-e.done:
+		return nil
+	}
+}
+
+// options wires the streams into StreamOptions
+func (e *execStreams) options(tty bool) remotecommand.StreamOptions {
+	opts := remotecommand.StreamOptions{
+		Stdin:  e,
+		Stdout: e.stdout,
+		Tty:    tty,
+	}
+	if tty {
+		opts.TerminalSizeQueue = e
+	} else {
+		opts.Stderr = e.stderr
+	}
+	return opts
+}
+
+// channelWriter writes output for one channel of an exec session
+type channelWriter struct {
+	session *execSession
+	channel byte
+}
+
+func (w channelWriter) Write(p []byte) (int, error) {
+	return w.session.write(w.channel, p)
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+)
+
+// RemoveFinalizers clears the finalizers of a resource stuck in Terminating
+// and returns those that were removed, or 409 if the resource is not being
+// deleted. Types are resolved as in GetResource; for namespaces the spec
+// finalizers are cleared too. Finalizers guard cleanup done by controllers,
+// so removing them may leak external resources. dryRun=true reports what
+// would be removed.
+func (h *Handler) RemoveFinalizers(c *fiber.Ctx) error {
+	if h.K8sManager.Clientset == nil {
+		return c.Status(fiber.StatusPreconditionFailed).JSON(fiber.Map{"error": "kubeconfig not loaded"})
+	}
+	dryRun, err := parseBoolQuery(c, "dryRun", false)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	resourceType := c.Params("type")
+	name := c.Params("name")
+	namespace := c.Query("namespace", "default")
+
+	ctx, cancel := h.requestContext(c)
+	defer cancel()
+
+	res, err := h.K8sManager.ResolveResource(ctx, resourceType)
+	if err != nil {
+		return h.sendResolveError(c, err)
+	}
+
+	removed, err := h.K8sManager.RemoveFinalizers(ctx, res, namespace, name, dryRun)
+	if err != nil {
+		return h.sendError(c, err)
+	}
+
+	h.requestLogger(c).Warn("removed finalizers", "type", res.GVR.Resource, "namespace", namespace, "name", name, "finalizers", removed, "dry_run", dryRun)
+	return c.JSON(fiber.Map{"removed": removed, "dryRun": dryRun})
+}
+
+// NamespaceTermination reports why a namespace is stuck terminating: its
+// finalizers, the deletion conditions set by the namespace controller, and
+// the objects still left in it along with their finalizers
+func (h *Handler) NamespaceTermination(c *fiber.Ctx) error {
+	if h.K8sManager.Clientset == nil {
+		return c.Status(fiber.StatusPreconditionFailed).JSON(fiber.Map{"error": "kubeconfig not loaded"})
+	}
+	ctx, cancel := h.requestContext(c)
+	defer cancel()
+
+	report, err := h.K8sManager.NamespaceTermination(ctx, c.Params("name"))
+	if err != nil {
+		return h.sendError(c, err)
+	}
+	return c.JSON(report)
+}
@@ -1,13 +1,91 @@
 package handlers
 
 import (
+	"context"
+	"log/slog"
+	"time"
+
 	"github.com/binodta/web-k9/backend/pkg/k8s"
+	"github.com/binodta/web-k9/backend/pkg/logging"
+	"github.com/binodta/web-k9/backend/pkg/portforward"
+	"github.com/binodta/web-k9/backend/pkg/recording"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/websocket/v2"
 )
 
+// DefaultRequestTimeout bounds Kubernetes calls made by REST handlers when
+// no timeout is configured
+const DefaultRequestTimeout = 30 * time.Second
+
+// Options configures handler behaviour
+type Options struct {
+	// RequestTimeout bounds the Kubernetes calls made while serving a REST request
+	RequestTimeout time.Duration
+	// DebugImages are the images allowed for ephemeral debug containers
+	DebugImages []string
+	// NodeShell configures the privileged pods used for node shells
+	NodeShell k8s.NodeShellOptions
+	// Recordings stores exec session recordings; nil disables recording
+	Recordings *recording.Store
+	// RecordingUserHeader names the request header identifying the user in
+	// recording metadata, as set by an authenticating proxy
+	RecordingUserHeader string
+	// PortForwardAddress is the host address port-forwards listen on
+	PortForwardAddress string
+}
+
 type Handler struct {
-	K8sManager *k8s.ClientManager
+	K8sManager   *k8s.ClientManager
+	Logger       *slog.Logger
+	Options      Options
+	PortForwards *portforward.Manager
+
+	transfers *transferHub
+}
+
+func NewHandler(manager *k8s.ClientManager, logger *slog.Logger, opts Options) *Handler {
+	if opts.RequestTimeout <= 0 {
+		opts.RequestTimeout = DefaultRequestTimeout
+	}
+	if len(opts.DebugImages) == 0 {
+		opts.DebugImages = DefaultDebugImages
+	}
+	if opts.NodeShell.Image == "" {
+		opts.NodeShell.Image = DefaultNodeShellImage
+	}
+	if opts.NodeShell.Namespace == "" {
+		opts.NodeShell.Namespace = DefaultNodeShellNamespace
+	}
+	if len(opts.NodeShell.Tolerations) == 0 {
+		opts.NodeShell.Tolerations, _ = k8s.ParseTolerations("")
+	}
+	if opts.PortForwardAddress == "" {
+		opts.PortForwardAddress = DefaultPortForwardAddress
+	}
+	return &Handler{
+		K8sManager:   manager,
+		Logger:       logger,
+		Options:      opts,
+		PortForwards: portforward.NewManager(opts.PortForwardAddress, logger),
+		transfers:    newTransferHub(),
+	}
+}
+
+// requestContext derives a context for Kubernetes calls from the request,
+// bounded by the configured request timeout
+func (h *Handler) requestContext(c *fiber.Ctx) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(c.UserContext(), h.Options.RequestTimeout)
+}
+
+// requestLogger returns the handler logger tagged with the request ID
+func (h *Handler) requestLogger(c *fiber.Ctx) *slog.Logger {
+	id, _ := c.Locals(logging.RequestIDKey).(string)
+	return h.Logger.With("request_id", id)
 }
 
-func NewHandler(manager *k8s.ClientManager) *Handler {
-	return &Handler{K8sManager: manager}
+// wsContext returns a base context for a WebSocket session carrying the
+// request ID of the upgrade request, plus a logger tagged with the same ID
+func (h *Handler) wsContext(c *websocket.Conn) (context.Context, *slog.Logger) {
+	id, _ := c.Locals(logging.RequestIDKey).(string)
+	return logging.WithRequestID(context.Background(), id), h.Logger.With("request_id", id)
 }
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// readinessTimeout bounds each probe against the API server
+const readinessTimeout = 3 * time.Second
+
+type readinessCheck struct {
+	OK        bool   `json:"ok"`
+	Optional  bool   `json:"optional,omitempty"`
+	LatencyMs int64  `json:"latencyMs,omitempty"`
+	Message   string `json:"message,omitempty"`
+}
+
+// Readyz reports whether the server can serve cluster data.
+// Optional checks, including informer sync, are reported but do not affect
+// the status code.
+func (h *Handler) Readyz(c *fiber.Ctx) error {
+	checks := map[string]readinessCheck{}
+
+	if h.K8sManager.Clientset == nil {
+		checks["kubeconfig"] = readinessCheck{Message: "kubeconfig not loaded"}
+	} else {
+		checks["kubeconfig"] = readinessCheck{OK: true, Message: h.K8sManager.SelectedContext}
+		checks["apiserver"] = h.probe(c.UserContext(), "/version", false)
+		checks["metricsServer"] = h.probe(c.UserContext(), "/apis/metrics.k8s.io/v1beta1", true)
+	}
+
+	// A type that cannot sync, such as one the user may not watch, only
+	// affects its own streams, so informers never fail readiness
+	byType := h.K8sManager.InformerSyncStatus()
+	running, synced := 0, 0
+	var unsynced []string
+	for resource, status := range byType {
+		running += status.Running
+		synced += status.Synced
+		if status.Synced < status.Running {
+			unsynced = append(unsynced, resource)
+		}
+	}
+	informers := readinessCheck{OK: len(unsynced) == 0, Optional: true}
+	if !informers.OK {
+		slices.Sort(unsynced)
+		informers.Message = "waiting for informer caches to sync: " + strings.Join(unsynced, ", ")
+	}
+	checks["informers"] = informers
+
+	ready := true
+	for _, check := range checks {
+		if !check.OK && !check.Optional {
+			ready = false
+		}
+	}
+
+	status := "ok"
+	code := fiber.StatusOK
+	if !ready {
+		status = "unavailable"
+		code = fiber.StatusServiceUnavailable
+	}
+
+	return c.Status(code).JSON(fiber.Map{
+		"status": status,
+		"checks": checks,
+		"informers": fiber.Map{
+			"running": running,
+			"synced":  synced,
+			"types":   byType,
+		},
+	})
+}
+
+// probe issues a GET against an API server path and records its latency
+func (h *Handler) probe(ctx context.Context, path string, optional bool) readinessCheck {
+	ctx, cancel := context.WithTimeout(ctx, readinessTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := h.K8sManager.Clientset.Discovery().RESTClient().Get().AbsPath(path).Do(ctx).Error()
+	check := readinessCheck{
+		OK:        err == nil,
+		Optional:  optional,
+		LatencyMs: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		check.Message = err.Error()
+	}
+	return check
+}
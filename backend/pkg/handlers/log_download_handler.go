@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// logTarget is one container whose log is part of a download
+type logTarget struct {
+	pod       string
+	container string
+}
+
+// DownloadLogs streams complete container logs as a file. A single container
+// is sent as a .log file; several containers (allContainers=true, a
+// multi-container pod, or the pods of a workload) are bundled as a .tar.gz
+// with one <pod>/<container>.log entry each. previous, sinceSeconds,
+// sinceTime, timestamps, tailLines and limitBytes are honoured.
+func (h *Handler) DownloadLogs(c *fiber.Ctx) error {
+	if h.K8sManager.Clientset == nil {
+		return c.Status(fiber.StatusPreconditionFailed).JSON(fiber.Map{"error": "kubeconfig not loaded"})
+	}
+	resourceType := c.Params("type")
+	name := c.Params("name")
+	namespace := c.Query("namespace", "default")
+
+	opts, err := parseLogOptions(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	opts.Follow = false
+	if c.Query("tailLines") == "" {
+		opts.TailLines = nil
+	}
+	allContainers, err := parseBoolQuery(c, "allContainers", false)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	ctx, cancel := h.requestContext(c)
+	defer cancel()
+
+	var pods []v1.Pod
+	switch resourceType {
+	case "pods", "po":
+		pod, err := h.K8sManager.Clientset.CoreV1().Pods(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return h.sendError(c, err)
+		}
+		pods = []v1.Pod{*pod}
+	default:
+		selector, err := h.K8sManager.WorkloadSelector(ctx, namespace, resourceType, name)
+		if err != nil {
+			return h.sendError(c, err)
+		}
+		list, err := h.K8sManager.Clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+		if err != nil {
+			return h.sendError(c, err)
+		}
+		pods = list.Items
+	}
+
+	var targets []logTarget
+	for _, pod := range pods {
+		for _, container := range append(pod.Spec.InitContainers, pod.Spec.Containers...) {
+			if opts.Container != "" && !allContainers && container.Name != opts.Container {
+				continue
+			}
+			targets = append(targets, logTarget{pod: pod.Name, container: container.Name})
+		}
+	}
+	if len(targets) == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "no containers found"})
+	}
+
+	// The body is written after this handler returns, so the streams must
+	// outlive the request timeout
+	streamCtx, streamCancel := context.WithCancel(c.UserContext())
+
+	if len(targets) == 1 {
+		t := targets[0]
+		opts.Container = t.container
+		stream, err := h.K8sManager.Clientset.CoreV1().Pods(namespace).GetLogs(t.pod, opts).Stream(streamCtx)
+		if err != nil {
+			streamCancel()
+			return h.sendError(c, err)
+		}
+		c.Set(fiber.HeaderContentType, fiber.MIMETextPlainCharsetUTF8)
+		c.Attachment(fmt.Sprintf("%s_%s.log", t.pod, t.container))
+		c.Context().SetBodyStream(&cancelOnCloseReader{ReadCloser: stream, cancel: streamCancel}, -1)
+		return nil
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		defer streamCancel()
+		pw.CloseWithError(h.writeLogArchive(streamCtx, pw, namespace, targets, opts))
+	}()
+
+	c.Set(fiber.HeaderContentType, "application/gzip")
+	c.Attachment(fmt.Sprintf("%s_%s_%s.tar.gz", resourceType, name, time.Now().UTC().Format("20060102T150405Z")))
+	c.Context().SetBodyStream(&cancelOnCloseReader{ReadCloser: pr, cancel: streamCancel}, -1)
+	return nil
+}
+
+// writeLogArchive writes a gzipped tarball with one entry per target. Tar
+// headers need the entry size up front, so each log is spooled to a temporary
+// file rather than held in memory. Logs that fail to download are recorded as
+// <pod>/<container>.error.txt entries instead of aborting the archive.
+func (h *Handler) writeLogArchive(ctx context.Context, w io.Writer, namespace string, targets []logTarget, opts *v1.PodLogOptions) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	for _, t := range targets {
+		entryOpts := opts.DeepCopy()
+		entryOpts.Container = t.container
+
+		path := fmt.Sprintf("%s/%s.log", t.pod, t.container)
+		if err := h.writeLogEntry(ctx, tw, path, namespace, t.pod, entryOpts); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			msg := []byte(err.Error() + "\n")
+			hdr := &tar.Header{
+				Name:    fmt.Sprintf("%s/%s.error.txt", t.pod, t.container),
+				Mode:    0o644,
+				Size:    int64(len(msg)),
+				ModTime: time.Now(),
+			}
+			if err := tw.WriteHeader(hdr); err != nil {
+				return err
+			}
+			if _, err := tw.Write(msg); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func (h *Handler) writeLogEntry(ctx context.Context, tw *tar.Writer, path string, namespace string, pod string, opts *v1.PodLogOptions) error {
+	stream, err := h.K8sManager.Clientset.CoreV1().Pods(namespace).GetLogs(pod, opts).Stream(ctx)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	spool, err := os.CreateTemp("", "webk9-log-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	size, err := io.Copy(spool, stream)
+	if err != nil {
+		return err
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	hdr := &tar.Header{Name: path, Mode: 0o644, Size: size, ModTime: time.Now()}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, spool)
+	return err
+}
+
+// cancelOnCloseReader releases a stream's context once the response body has
+// been fully sent or the client has gone away
+type cancelOnCloseReader struct {
+	io.ReadCloser
+	cancel func()
+}
+
+func (r *cancelOnCloseReader) Close() error {
+	err := r.ReadCloser.Close()
+	r.cancel()
+	return err
+}
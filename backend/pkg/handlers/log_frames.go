@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/gofiber/websocket/v2"
+	v1 "k8s.io/api/core/v1"
+)
+
+// logFrame is one log line sent to clients that request format=json
+type logFrame struct {
+	Pod       string `json:"pod"`
+	Container string `json:"container,omitempty"`
+	Timestamp string `json:"timestamp,omitempty"`
+	Line      string `json:"line"`
+	Level     string `json:"level,omitempty"`
+	Message   string `json:"message,omitempty"`
+}
+
+// Keys checked, in order, when extracting fields from JSON log lines
+var (
+	jsonLevelKeys   = []string{"level", "lvl", "severity", "loglevel", "log.level"}
+	jsonMessageKeys = []string{"msg", "message", "log"}
+)
+
+// logSink filters log lines and serialises them from concurrent log streams
+// onto one WebSocket
+type logSink struct {
+	mu   sync.Mutex
+	conn *websocket.Conn
+
+	// prefix tags text lines with their pod and container
+	prefix bool
+	// jsonFrames sends logFrame messages instead of text
+	jsonFrames bool
+	// timestamps is set when lines carry the API server's timestamp prefix
+	timestamps bool
+	// parseJSON extracts level and message from JSON formatted lines
+	parseJSON bool
+
+	include *regexp.Regexp
+	exclude *regexp.Regexp
+}
+
+// newLogSink configures a sink from the format, include, exclude and
+// parseJson query parameters. JSON frames always carry a timestamp, so opts
+// is updated to request one from the API server.
+func newLogSink(c *websocket.Conn, opts *v1.PodLogOptions) (*logSink, error) {
+	sink := &logSink{conn: c}
+
+	switch format := c.Query("format", "text"); format {
+	case "text":
+	case "json":
+		sink.jsonFrames = true
+		opts.Timestamps = true
+	default:
+		return nil, fmt.Errorf("invalid format %q: must be text or json", format)
+	}
+	sink.timestamps = opts.Timestamps
+
+	var err error
+	if sink.parseJSON, err = parseBoolQuery(c, "parseJson", false); err != nil {
+		return nil, err
+	}
+	if v := c.Query("include"); v != "" {
+		if sink.include, err = regexp.Compile(v); err != nil {
+			return nil, fmt.Errorf("invalid include pattern: %w", err)
+		}
+	}
+	if v := c.Query("exclude"); v != "" {
+		if sink.exclude, err = regexp.Compile(v); err != nil {
+			return nil, fmt.Errorf("invalid exclude pattern: %w", err)
+		}
+	}
+	return sink, nil
+}
+
+// writeLine sends one raw log line, dropping it if it does not pass the filters
+func (s *logSink) writeLine(pod string, container string, line string) error {
+	line = strings.TrimRight(line, "\r\n")
+
+	var timestamp string
+	if s.timestamps {
+		if i := strings.IndexByte(line, ' '); i > 0 {
+			timestamp, line = line[:i], line[i+1:]
+		}
+	}
+
+	if s.include != nil && !s.include.MatchString(line) {
+		return nil
+	}
+	if s.exclude != nil && s.exclude.MatchString(line) {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.jsonFrames {
+		frame := logFrame{Pod: pod, Container: container, Timestamp: timestamp, Line: line}
+		if s.parseJSON {
+			frame.Level, frame.Message = parseJSONLogLine(line)
+		}
+		return s.conn.WriteJSON(frame)
+	}
+
+	if timestamp != "" {
+		line = timestamp + " " + line
+	}
+	if s.prefix {
+		line = "[" + pod + "/" + container + "] " + line
+	}
+	return s.conn.WriteMessage(websocket.TextMessage, []byte(line+"\n"))
+}
+
+func (s *logSink) writeError(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	writeWSError(s.conn, err)
+}
+
+// parseJSONLogLine returns the severity and message of a structured log line.
+// Lines that are not JSON objects yield empty strings.
+func parseJSONLogLine(line string) (level string, message string) {
+	if !strings.HasPrefix(line, "{") {
+		return "", ""
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(line), &fields); err != nil {
+		return "", ""
+	}
+
+	for _, key := range jsonLevelKeys {
+		if v, ok := fields[key]; ok {
+			level = normalizeLevel(v)
+			break
+		}
+	}
+	for _, key := range jsonMessageKeys {
+		if v, ok := fields[key].(string); ok {
+			message = v
+			break
+		}
+	}
+	return level, message
+}
+
+// normalizeLevel lower-cases textual levels and maps the numeric levels used
+// by bunyan and pino onto their names
+func normalizeLevel(v interface{}) string {
+	switch level := v.(type) {
+	case string:
+		return strings.ToLower(level)
+	case float64:
+		switch {
+		case level >= 60:
+			return "fatal"
+		case level >= 50:
+			return "error"
+		case level >= 40:
+			return "warn"
+		case level >= 30:
+			return "info"
+		case level >= 20:
+			return "debug"
+		default:
+			return "trace"
+		}
+	}
+	return ""
+}
@@ -0,0 +1,392 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/binodta/web-k9/backend/pkg/k8s"
+	"github.com/binodta/web-k9/backend/pkg/metrics"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/websocket/v2"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+)
+
+// defaultTailLines is how many lines are shown when tailLines is not given
+const defaultTailLines = int64(100)
+
+// queryReader is implemented by both *fiber.Ctx and *websocket.Conn
+type queryReader interface {
+	Query(key string, defaultValue ...string) string
+}
+
+// parseLogOptions builds PodLogOptions from the previous, sinceSeconds,
+// sinceTime, timestamps, tailLines, limitBytes and follow query parameters.
+// tailLines accepts "all" (or -1) to return the whole log.
+func parseLogOptions(q queryReader) (*v1.PodLogOptions, error) {
+	opts := &v1.PodLogOptions{
+		Container: q.Query("container"),
+	}
+
+	var err error
+	if opts.Follow, err = parseBoolQuery(q, "follow", true); err != nil {
+		return nil, err
+	}
+	if opts.Previous, err = parseBoolQuery(q, "previous", false); err != nil {
+		return nil, err
+	}
+	if opts.Timestamps, err = parseBoolQuery(q, "timestamps", false); err != nil {
+		return nil, err
+	}
+
+	switch tail := q.Query("tailLines"); tail {
+	case "":
+		lines := defaultTailLines
+		opts.TailLines = &lines
+	case "all", "-1":
+		// no limit
+	default:
+		lines, err := strconv.ParseInt(tail, 10, 64)
+		if err != nil || lines < 0 {
+			return nil, fmt.Errorf("invalid tailLines %q: must be a non-negative integer or \"all\"", tail)
+		}
+		opts.TailLines = &lines
+	}
+
+	if v := q.Query("sinceSeconds"); v != "" {
+		seconds, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || seconds <= 0 {
+			return nil, fmt.Errorf("invalid sinceSeconds %q: must be a positive integer", v)
+		}
+		opts.SinceSeconds = &seconds
+	}
+	if v := q.Query("sinceTime"); v != "" {
+		if opts.SinceSeconds != nil {
+			return nil, fmt.Errorf("sinceSeconds and sinceTime are mutually exclusive")
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sinceTime %q: must be RFC3339", v)
+		}
+		since := metav1.NewTime(t)
+		opts.SinceTime = &since
+	}
+
+	if v := q.Query("limitBytes"); v != "" {
+		limit, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || limit <= 0 {
+			return nil, fmt.Errorf("invalid limitBytes %q: must be a positive integer", v)
+		}
+		opts.LimitBytes = &limit
+	}
+
+	return opts, nil
+}
+
+func parseBoolQuery(q queryReader, key string, defaultValue bool) (bool, error) {
+	v := q.Query(key)
+	if v == "" {
+		return defaultValue, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s %q: must be true or false", key, v)
+	}
+	return b, nil
+}
+
+// parseDurationQuery reads a duration query parameter such as 90s or 5m
+func parseDurationQuery(q queryReader, key string, defaultValue time.Duration) (time.Duration, error) {
+	v := q.Query(key)
+	if v == "" {
+		return defaultValue, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid %s %q: must be a positive duration such as 90s or 5m", key, v)
+	}
+	return d, nil
+}
+
+// parseOptionalDurationQuery is parseDurationQuery for limits that can be
+// turned off: 0 is accepted and means no limit
+func parseOptionalDurationQuery(q queryReader, key string, defaultValue time.Duration) (time.Duration, error) {
+	v := q.Query(key)
+	if v == "" {
+		return defaultValue, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid %s %q: must be 0 or a positive duration such as 90s or 5m", key, v)
+	}
+	return d, nil
+}
+
+// StreamLogs handles WebSocket connections for real-time log streaming.
+// It tails a single pod, or every pod matched by a label selector or owned
+// by a workload (kind and name), in which case each line is prefixed with
+// its pod and container. Every message carries one line, either as text or,
+// with format=json, as a logFrame.
+func (h *Handler) StreamLogs(c *websocket.Conn) {
+	defer metrics.TrackWebSocket("logs")()
+	if h.K8sManager.Clientset == nil {
+		c.WriteJSON(fiber.Map{"error": "kubeconfig not loaded"})
+		return
+	}
+	namespace := c.Query("namespace", "default")
+	pod := c.Query("pod")
+	selector := c.Query("selector")
+	kind := c.Query("kind")
+	name := c.Query("name")
+
+	if pod == "" && selector == "" && (kind == "" || name == "") {
+		c.WriteJSON(fiber.Map{"error": "pod, selector or kind and name are required"})
+		return
+	}
+
+	opts, err := parseLogOptions(c)
+	if err != nil {
+		c.WriteJSON(fiber.Map{"error": err.Error()})
+		return
+	}
+
+	baseCtx, logger := h.wsContext(c)
+	ctx, cancel := context.WithCancel(baseCtx)
+	defer cancel()
+	cancelOnClose(c, cancel)
+
+	sink, err := newLogSink(c, opts)
+	if err != nil {
+		c.WriteJSON(fiber.Map{"error": err.Error()})
+		return
+	}
+
+	if pod != "" {
+		if err := h.streamContainerLogs(ctx, sink, namespace, pod, opts); err != nil && ctx.Err() == nil {
+			logger.Warn("log stream failed", "namespace", namespace, "pod", pod, "container", opts.Container, "error", err)
+			sink.writeError(err)
+		}
+		return
+	}
+
+	if selector == "" {
+		selector, err = h.K8sManager.WorkloadSelector(ctx, namespace, kind, name)
+		if err != nil {
+			sink.writeError(err)
+			return
+		}
+	}
+
+	sink.prefix = true
+	if opts.Follow {
+		h.followSelectorLogs(ctx, cancel, logger, sink, namespace, selector, opts)
+	} else {
+		h.dumpSelectorLogs(ctx, logger, sink, namespace, selector, opts)
+	}
+}
+
+// streamContainerLogs copies one container's log to the sink line by line
+func (h *Handler) streamContainerLogs(ctx context.Context, sink *logSink, namespace string, pod string, opts *v1.PodLogOptions) error {
+	stream, err := h.K8sManager.Clientset.CoreV1().Pods(namespace).GetLogs(pod, opts).Stream(ctx)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	reader := bufio.NewReader(stream)
+	for {
+		line, err := reader.ReadString('\n')
+		if len(line) > 0 {
+			if err := sink.writeLine(pod, opts.Container, line); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+type logStreamKey struct {
+	pod       string
+	container string
+}
+
+// followSelectorLogs tails every container of the pods matching selector.
+// Pods are tracked with an informer that never drops events, so pods
+// created during the session are picked up and streams of deleted pods are
+// stopped. A container that restarts is re-attached from the time its
+// previous stream ended.
+func (h *Handler) followSelectorLogs(ctx context.Context, cancel context.CancelFunc, logger *slog.Logger, sink *logSink, namespace string, selector string, opts *v1.PodLogOptions) {
+	events := make(chan k8s.ResourceEvent, resourceEventBuffer)
+	if err := h.K8sManager.WatchResourcesLossless(ctx, "pods", namespace, selector, "", events); err != nil {
+		sink.writeError(err)
+		return
+	}
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	defer cancel()
+
+	pods := map[string]*v1.Pod{}
+	active := map[logStreamKey]context.CancelFunc{}
+	restarts := map[logStreamKey]int32{}
+	ended := map[logStreamKey]time.Time{}
+	// owners records which pod restarts and ended refer to, so a pod
+	// recreated under the same name starts afresh
+	owners := map[logStreamKey]types.UID{}
+	done := make(chan logStreamKey)
+
+	start := func(key logStreamKey, streamOpts *v1.PodLogOptions) {
+		streamCtx, stop := context.WithCancel(ctx)
+		active[key] = stop
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer stop()
+			err := h.streamContainerLogs(streamCtx, sink, namespace, key.pod, streamOpts)
+			if err != nil && streamCtx.Err() == nil {
+				logger.Debug("container log stream ended", "pod", key.pod, "container", key.container, "error", err)
+			}
+			select {
+			case done <- key:
+			case <-ctx.Done():
+			}
+		}()
+	}
+
+	// follow starts streams for the containers of pod that are not being
+	// tailed and have started, or restarted since they were last tailed
+	follow := func(pod *v1.Pod) {
+		for _, status := range podContainerStatuses(pod) {
+			if opts.Container != "" && status.Name != opts.Container {
+				continue
+			}
+			key := logStreamKey{pod: pod.Name, container: status.Name}
+			if _, running := active[key]; running {
+				continue
+			}
+			if owner, ok := owners[key]; ok && owner != pod.UID {
+				delete(restarts, key)
+				delete(ended, key)
+			}
+			if status.State.Running == nil && status.State.Terminated == nil {
+				continue
+			}
+			if last, seen := restarts[key]; seen && status.RestartCount <= last {
+				continue
+			}
+			restarts[key] = status.RestartCount
+			owners[key] = pod.UID
+
+			streamOpts := opts.DeepCopy()
+			streamOpts.Container = status.Name
+			if t, ok := ended[key]; ok {
+				since := metav1.NewTime(t)
+				streamOpts.SinceTime = &since
+				streamOpts.SinceSeconds = nil
+				streamOpts.TailLines = nil
+			}
+			start(key, streamOpts)
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case key := <-done:
+			delete(active, key)
+			pod, ok := pods[key.pod]
+			if !ok {
+				continue
+			}
+			if owners[key] == pod.UID {
+				ended[key] = time.Now()
+			}
+			// Status changes seen while the stream was closing were skipped
+			follow(pod)
+		case event := <-events:
+			pod, ok := podFromEvent(event.Object)
+			if !ok {
+				continue
+			}
+			if event.Type == "DELETED" {
+				delete(pods, pod.Name)
+				for key, stop := range active {
+					if key.pod == pod.Name {
+						stop()
+					}
+				}
+				for key := range owners {
+					if key.pod == pod.Name {
+						delete(restarts, key)
+						delete(ended, key)
+						delete(owners, key)
+					}
+				}
+				continue
+			}
+			pods[pod.Name] = pod
+			follow(pod)
+		}
+	}
+}
+
+// dumpSelectorLogs writes the current logs of every container of the pods
+// matching selector, then returns
+func (h *Handler) dumpSelectorLogs(ctx context.Context, logger *slog.Logger, sink *logSink, namespace string, selector string, opts *v1.PodLogOptions) {
+	pods, err := h.K8sManager.Clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		sink.writeError(err)
+		return
+	}
+
+	var wg sync.WaitGroup
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		for _, status := range podContainerStatuses(pod) {
+			if opts.Container != "" && status.Name != opts.Container {
+				continue
+			}
+			streamOpts := opts.DeepCopy()
+			streamOpts.Container = status.Name
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := h.streamContainerLogs(ctx, sink, namespace, pod.Name, streamOpts); err != nil && ctx.Err() == nil {
+					logger.Debug("container log stream failed", "pod", pod.Name, "container", streamOpts.Container, "error", err)
+				}
+			}()
+		}
+	}
+	wg.Wait()
+}
+
+// podFromEvent extracts the pod from an informer event, including the
+// tombstones delivered for deletes that were missed while disconnected
+func podFromEvent(obj interface{}) (*v1.Pod, bool) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	pod, ok := obj.(*v1.Pod)
+	return pod, ok
+}
+
+// podContainerStatuses returns init container statuses followed by regular ones
+func podContainerStatuses(pod *v1.Pod) []v1.ContainerStatus {
+	statuses := make([]v1.ContainerStatus, 0, len(pod.Status.InitContainerStatuses)+len(pod.Status.ContainerStatuses))
+	statuses = append(statuses, pod.Status.InitContainerStatuses...)
+	return append(statuses, pod.Status.ContainerStatuses...)
+}
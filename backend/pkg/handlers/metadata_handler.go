@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"github.com/binodta/web-k9/backend/pkg/k8s"
+	"github.com/gofiber/fiber/v2"
+)
+
+// PatchLabels sets and removes labels of one object, or of every object
+// matching labelSelector or fieldSelector when the name is "-". The
+// request body is
+//
+//	{"set": {"team": "payments"}, "remove": ["legacy"]}
+//
+// and the response shows the labels before and after the change.
+func (h *Handler) PatchLabels(c *fiber.Ctx) error {
+	return h.patchMetadata(c, "labels")
+}
+
+// PatchAnnotations is PatchLabels for annotations
+func (h *Handler) PatchAnnotations(c *fiber.Ctx) error {
+	return h.patchMetadata(c, "annotations")
+}
+
+func (h *Handler) patchMetadata(c *fiber.Ctx, field string) error {
+	if h.K8sManager.Clientset == nil {
+		return c.Status(fiber.StatusPreconditionFailed).JSON(fiber.Map{"error": "kubeconfig not loaded"})
+	}
+	var change k8s.MetadataChange
+	if err := c.BodyParser(&change); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid body"})
+	}
+	if err := change.Validate(field); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	namespace := c.Query("namespace", "default")
+	if c.QueryBool("allNamespaces") {
+		namespace = ""
+	}
+
+	ctx, cancel := h.requestContext(c)
+	defer cancel()
+	logger := h.requestLogger(c)
+
+	res, err := h.K8sManager.ResolveResource(ctx, c.Params("type"))
+	if err != nil {
+		return h.sendResolveError(c, err)
+	}
+
+	name := c.Params("name")
+	if name != "" {
+		result, err := h.K8sManager.PatchMetadata(ctx, res, namespace, name, field, change)
+		if err != nil {
+			return h.sendError(c, err)
+		}
+		logger.Info("patched "+field, "type", res.GVR.Resource, "namespace", result.Namespace, "name", name)
+		return c.JSON(result)
+	}
+
+	labelSelector := c.Query("labelSelector")
+	fieldSelector := c.Query("fieldSelector")
+	if labelSelector == "" && fieldSelector == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "labelSelector or fieldSelector is required to change several objects"})
+	}
+	results, err := h.K8sManager.PatchMetadataBySelector(ctx, res, namespace, labelSelector, fieldSelector, field, change)
+	if err != nil {
+		return h.sendError(c, err)
+	}
+	logger.Info("patched "+field+" by selector", "type", res.GVR.Resource, "namespace", namespace, "label_selector", labelSelector, "field_selector", fieldSelector, "count", len(results))
+	return c.JSON(fiber.Map{"items": results})
+}
+
+// PatchTaints adds and removes taints of one node, or of every node matching
+// labelSelector when no name is given. The request body is
+//
+//	{"add": [{"key": "dedicated", "value": "gpu", "effect": "NoSchedule"}], "remove": [{"key": "maintenance"}]}
+//
+// and the response shows the taints before and after the change.
+func (h *Handler) PatchTaints(c *fiber.Ctx) error {
+	if h.K8sManager.Clientset == nil {
+		return c.Status(fiber.StatusPreconditionFailed).JSON(fiber.Map{"error": "kubeconfig not loaded"})
+	}
+	var change k8s.TaintChange
+	if err := c.BodyParser(&change); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid body"})
+	}
+	if err := change.Validate(); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	ctx, cancel := h.requestContext(c)
+	defer cancel()
+	logger := h.requestLogger(c)
+
+	name := c.Params("name")
+	if name != "" {
+		result, err := h.K8sManager.PatchTaints(ctx, name, change)
+		if err != nil {
+			return h.sendError(c, err)
+		}
+		logger.Info("patched taints", "node", name)
+		return c.JSON(result)
+	}
+
+	labelSelector := c.Query("labelSelector")
+	if labelSelector == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "labelSelector is required to change several nodes"})
+	}
+	results, err := h.K8sManager.PatchTaintsBySelector(ctx, labelSelector, change)
+	if err != nil {
+		return h.sendError(c, err)
+	}
+	logger.Info("patched taints by selector", "label_selector", labelSelector, "count", len(results))
+	return c.JSON(fiber.Map{"items": results})
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"context"
+	"strconv"
+	"sync"
+
+	"github.com/binodta/web-k9/backend/pkg/k8s"
+	"github.com/binodta/web-k9/backend/pkg/metrics"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/websocket/v2"
+)
+
+// CordonNode marks a node unschedulable
+func (h *Handler) CordonNode(c *fiber.Ctx) error {
+	return h.setUnschedulable(c, true)
+}
+
+// UncordonNode marks a node schedulable again
+func (h *Handler) UncordonNode(c *fiber.Ctx) error {
+	return h.setUnschedulable(c, false)
+}
+
+func (h *Handler) setUnschedulable(c *fiber.Ctx, unschedulable bool) error {
+	if h.K8sManager.Clientset == nil {
+		return c.Status(fiber.StatusPreconditionFailed).JSON(fiber.Map{"error": "kubeconfig not loaded"})
+	}
+	node := c.Params("name")
+
+	ctx, cancel := h.requestContext(c)
+	defer cancel()
+
+	if err := h.K8sManager.SetUnschedulable(ctx, node, unschedulable); err != nil {
+		return h.sendError(c, err)
+	}
+	h.requestLogger(c).Info("changed node schedulability", "node", node, "unschedulable", unschedulable)
+	return c.JSON(fiber.Map{"name": node, "unschedulable": unschedulable})
+}
+
+// DrainNode handles WebSocket connections that cordon and drain a node,
+// streaming a k8s.DrainEvent per step and pod. Options follow kubectl drain:
+// ignoreDaemonsets, deleteEmptyDirData, force, gracePeriod (seconds, -1 to
+// use each pod's own) and timeout (default 5m, 0 for no limit). Closing the
+// connection stops the drain; the node stays cordoned.
+func (h *Handler) DrainNode(c *websocket.Conn) {
+	defer metrics.TrackWebSocket("drain")()
+	if h.K8sManager.Clientset == nil {
+		c.WriteJSON(fiber.Map{"error": "kubeconfig not loaded"})
+		return
+	}
+	node := c.Query("node")
+	if node == "" {
+		c.WriteJSON(fiber.Map{"error": "node name is required"})
+		return
+	}
+
+	opts := k8s.DrainOptions{GracePeriodSeconds: -1}
+	var err error
+	if opts.IgnoreDaemonSets, err = parseBoolQuery(c, "ignoreDaemonsets", false); err != nil {
+		c.WriteJSON(fiber.Map{"error": err.Error()})
+		return
+	}
+	if opts.DeleteEmptyDirData, err = parseBoolQuery(c, "deleteEmptyDirData", false); err != nil {
+		c.WriteJSON(fiber.Map{"error": err.Error()})
+		return
+	}
+	if opts.Force, err = parseBoolQuery(c, "force", false); err != nil {
+		c.WriteJSON(fiber.Map{"error": err.Error()})
+		return
+	}
+	if v := c.Query("gracePeriod"); v != "" {
+		if opts.GracePeriodSeconds, err = strconv.ParseInt(v, 10, 64); err != nil {
+			c.WriteJSON(fiber.Map{"error": "invalid gracePeriod: must be a number of seconds"})
+			return
+		}
+	}
+	if opts.Timeout, err = parseOptionalDurationQuery(c, "timeout", defaultWaitTimeout); err != nil {
+		c.WriteJSON(fiber.Map{"error": err.Error()})
+		return
+	}
+
+	baseCtx, logger := h.wsContext(c)
+	ctx, cancel := context.WithCancel(baseCtx)
+	defer cancel()
+	cancelOnClose(c, cancel)
+
+	var writeMu sync.Mutex
+	progress := func(event k8s.DrainEvent) {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		c.WriteJSON(event)
+	}
+
+	logger.Info("draining node", "node", node, "ignore_daemonsets", opts.IgnoreDaemonSets, "delete_emptydir_data", opts.DeleteEmptyDirData, "force", opts.Force)
+	if err := h.K8sManager.DrainNode(ctx, node, opts, progress); err != nil {
+		logger.Warn("drain failed", "node", node, "error", err)
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		writeWSError(c, err)
+		return
+	}
+	logger.Info("drained node", "node", node)
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/binodta/web-k9/backend/pkg/metrics"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/websocket/v2"
+)
+
+// Defaults for the privileged pods that back node shells
+const (
+	DefaultNodeShellImage     = "busybox:1.36"
+	DefaultNodeShellNamespace = "kube-system"
+)
+
+// nodeShellCleanupTimeout bounds the deletion of a node shell pod once its
+// session has ended
+const nodeShellCleanupTimeout = 30 * time.Second
+
+// NodeShell handles WebSocket connections for a root shell on a node. A
+// privileged pod is scheduled on the node, enters the host namespaces with
+// nsenter, and is attached to using the same protocols as ExecShell. The pod
+// is deleted when the session ends.
+func (h *Handler) NodeShell(c *websocket.Conn) {
+	defer metrics.TrackWebSocket("node-shell")()
+	if h.K8sManager.Clientset == nil || h.K8sManager.Config == nil {
+		c.WriteJSON(fiber.Map{"error": "kubeconfig not loaded"})
+		return
+	}
+	node := c.Query("node")
+	if node == "" {
+		c.WriteJSON(fiber.Map{"error": "node name is required"})
+		return
+	}
+
+	baseCtx, logger := h.wsContext(c)
+	ctx, cancel := context.WithCancel(baseCtx)
+	defer cancel()
+
+	session := newExecSession(ctx, c, cancel, nil)
+	opts := h.Options.NodeShell
+
+	pod, err := h.K8sManager.CreateNodeShell(ctx, node, opts)
+	if err != nil {
+		logger.Warn("failed to create node shell pod", "node", node, "error", err)
+		session.finish(err)
+		return
+	}
+	logger.Info("created node shell pod", "node", node, "namespace", pod.Namespace, "pod", pod.Name, "image", opts.Image)
+
+	defer func() {
+		cleanupCtx, cleanupCancel := context.WithTimeout(context.Background(), nodeShellCleanupTimeout)
+		defer cleanupCancel()
+		if err := h.K8sManager.DeleteNodeShell(cleanupCtx, pod.Namespace, pod.Name); err != nil {
+			logger.Warn("failed to delete node shell pod", "error", err)
+			return
+		}
+		logger.Info("deleted node shell pod", "namespace", pod.Namespace, "pod", pod.Name)
+	}()
+
+	session.write(stdoutChannel, []byte(fmt.Sprintf("Starting shell on node %s...\r\n", node)))
+
+	startCtx, startCancel := context.WithTimeout(ctx, debugStartTimeout)
+	err = h.K8sManager.WaitForPodRunning(startCtx, pod.Namespace, pod.Name)
+	startCancel()
+	if err != nil {
+		if ctx.Err() == nil {
+			session.finish(err)
+		}
+		return
+	}
+
+	executor, err := h.newAttachExecutor(pod.Namespace, pod.Name, pod.Spec.Containers[0].Name, true, true)
+	if err != nil {
+		session.finish(err)
+		return
+	}
+
+	// The shell may have printed its prompt before the attach, as with kubectl
+	session.write(stdoutChannel, []byte("If you don't see a command prompt, try pressing enter.\r\n"))
+
+	err = executor.StreamWithContext(ctx, session.streams(ctx).options(true))
+	if ctx.Err() != nil {
+		return
+	}
+	if err != nil {
+		logger.Info("node shell session ended with error", "error", err)
+	}
+	session.finish(err)
+}
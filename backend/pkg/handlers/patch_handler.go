@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"mime"
+
+	"github.com/gofiber/fiber/v2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// fieldManager identifies webk9 in managedFields for changes it makes
+const fieldManager = "webk9"
+
+// patchTypes maps request content types to the patch types they carry
+var patchTypes = map[string]types.PatchType{
+	"application/json-patch+json":            types.JSONPatchType,
+	"application/merge-patch+json":           types.MergePatchType,
+	"application/strategic-merge-patch+json": types.StrategicMergePatchType,
+}
+
+// PatchResource applies a JSON patch, JSON merge patch or strategic merge
+// patch to a resource, chosen by the Content-Type header, and returns the
+// patched object. Types are resolved as in GetResource. Custom resources
+// do not support strategic merge patches. dryRun=true validates the patch
+// and returns the result without persisting it.
+func (h *Handler) PatchResource(c *fiber.Ctx) error {
+	if h.K8sManager.Clientset == nil {
+		return c.Status(fiber.StatusPreconditionFailed).JSON(fiber.Map{"error": "kubeconfig not loaded"})
+	}
+	mediaType, _, _ := mime.ParseMediaType(c.Get(fiber.HeaderContentType))
+	patchType, ok := patchTypes[mediaType]
+	if !ok {
+		return c.Status(fiber.StatusUnsupportedMediaType).JSON(fiber.Map{
+			"error": "Content-Type must be application/json-patch+json, application/merge-patch+json or application/strategic-merge-patch+json",
+		})
+	}
+	patch := c.Body()
+	if len(patch) == 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "patch body is required"})
+	}
+	dryRun, err := parseBoolQuery(c, "dryRun", false)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	resourceType := c.Params("type")
+	name := c.Params("name")
+	namespace := c.Query("namespace", "default")
+
+	ctx, cancel := h.requestContext(c)
+	defer cancel()
+
+	res, err := h.K8sManager.ResolveResource(ctx, resourceType)
+	if err != nil {
+		return h.sendResolveError(c, err)
+	}
+
+	opts := metav1.PatchOptions{FieldManager: fieldManager}
+	if dryRun {
+		opts.DryRun = []string{metav1.DryRunAll}
+	}
+	obj, err := h.K8sManager.ResourceInterface(res, namespace).Patch(ctx, name, patchType, patch, opts)
+	if err != nil {
+		return h.sendError(c, err)
+	}
+
+	h.requestLogger(c).Info("patched resource", "type", res.GVR.Resource, "namespace", obj.GetNamespace(), "name", name, "patch_type", string(patchType), "dry_run", dryRun)
+	return c.JSON(obj)
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/binodta/web-k9/backend/pkg/k8s"
+	"github.com/binodta/web-k9/backend/pkg/portforward"
+	"github.com/gofiber/fiber/v2"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// DefaultPortForwardAddress keeps port-forwards reachable only from the
+// webk9 host unless configured otherwise
+const DefaultPortForwardAddress = "127.0.0.1"
+
+// StartPortForward forwards a local port on the webk9 host to a pod,
+// service or workload. Services and workloads are resolved to a ready pod,
+// and the forward moves to another pod when that one goes away. The
+// request body is
+//
+//	{"namespace": "default", "kind": "services", "name": "web", "port": 80, "localPort": 8080}
+func (h *Handler) StartPortForward(c *fiber.Ctx) error {
+	if h.K8sManager.Clientset == nil || h.K8sManager.Config == nil {
+		return c.Status(fiber.StatusPreconditionFailed).JSON(fiber.Map{"error": "kubeconfig not loaded"})
+	}
+	var req portforward.Request
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid body"})
+	}
+	if req.Name == "" || req.Port.String() == "0" || req.Port.String() == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "name and port are required"})
+	}
+	if req.Port.Type == intstr.Int && (req.Port.IntVal < 0 || req.Port.IntVal > 65535) {
+		return c.Status(400).JSON(fiber.Map{"error": "port must be between 1 and 65535"})
+	}
+
+	ctx, cancel := h.requestContext(c)
+	defer cancel()
+
+	info, err := h.PortForwards.Start(ctx, h.K8sManager.Config, h.K8sManager.Clientset, h.K8sManager.SelectedContext, req)
+	switch {
+	case errors.Is(err, portforward.ErrInvalidRequest):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, portforward.ErrPortInUse):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, k8s.ErrPortNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, k8s.ErrPodUnavailable):
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": err.Error()})
+	case err != nil:
+		return h.sendError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(info)
+}
+
+// ListPortForwards returns every port-forward with its status and counters
+func (h *Handler) ListPortForwards(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{"portForwards": h.PortForwards.List()})
+}
+
+// GetPortForward returns one port-forward
+func (h *Handler) GetPortForward(c *fiber.Ctx) error {
+	info, err := h.PortForwards.Get(c.Params("id"))
+	if errors.Is(err, portforward.ErrNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(info)
+}
+
+// StopPortForward closes a port-forward and its open connections
+func (h *Handler) StopPortForward(c *fiber.Ctx) error {
+	info, err := h.PortForwards.Stop(c.Params("id"))
+	if errors.Is(err, portforward.ErrNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(info)
+}
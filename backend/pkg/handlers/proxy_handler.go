@@ -0,0 +1,271 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// proxyCookiePrefix marks cookies set by proxied services. Only cookies
+// carrying it are forwarded, so webk9's own cookies never reach a service.
+const proxyCookiePrefix = "webk9proxy_"
+
+// proxyRewriteLimit caps the size of HTML bodies whose links are rewritten;
+// larger pages are passed through unchanged
+const proxyRewriteLimit = 8 << 20
+
+// proxySandbox is the Content-Security-Policy of proxied responses. Without
+// allow-same-origin, pages run in an opaque origin, so a service's scripts
+// cannot use the webk9 API with the user's session.
+const proxySandbox = "sandbox allow-scripts allow-forms allow-popups allow-downloads"
+
+// hopHeaders are connection-specific and must not be forwarded by proxies
+var hopHeaders = []string{
+	"Connection", "Proxy-Connection", "Keep-Alive", "Proxy-Authenticate",
+	"Proxy-Authorization", "Te", "Trailer", "Transfer-Encoding", "Upgrade",
+}
+
+// ProxyService reverse-proxies HTTP requests to a service through the API
+// server's service proxy subresource of the given context. port is a port
+// number or name, optionally prefixed with https: to reach a TLS backend.
+// Redirects, cookie paths and the API server proxy paths that the API
+// server writes into HTML links are rewritten under /proxy/..., and the
+// prefix is sent as X-Forwarded-Prefix for applications that honour it.
+// Root-relative URLs built by scripts are not rewritten, so single-page
+// applications that assume they are served from / may not work; API-style
+// services always do. Service cookies are renamed with proxyCookiePrefix,
+// and only those are forwarded. Responses are sandboxed with proxySandbox,
+// so pages relying on their own origin, such as for local storage, may not
+// work. WebSocket upgrades are not proxied.
+func (h *Handler) ProxyService(c *fiber.Ctx) error {
+	contextName, err := url.PathUnescape(c.Params("context"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid context"})
+	}
+	namespace := c.Params("namespace")
+	service := c.Params("service")
+	port := c.Params("port")
+
+	prefix := fmt.Sprintf("/proxy/%s/%s/%s/%s", url.PathEscape(contextName), namespace, service, port)
+	path := c.Params("*")
+	if path == "" && !strings.HasSuffix(c.Path(), "/") {
+		// Relative links only resolve against the service root with a trailing slash
+		target := prefix + "/"
+		if q := string(c.Request().URI().QueryString()); q != "" {
+			target += "?" + q
+		}
+		return c.Redirect(target, fiber.StatusMovedPermanently)
+	}
+
+	config, transport, err := h.K8sManager.ContextTransport(contextName)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	// service:port, or scheme:service:port for https backends
+	serviceSegment := service + ":" + port
+	if scheme, rest, ok := strings.Cut(port, ":"); ok && (scheme == "http" || scheme == "https") {
+		serviceSegment = scheme + ":" + service + ":" + rest
+	}
+	apiPrefix := fmt.Sprintf("/api/v1/namespaces/%s/services/%s/proxy", namespace, serviceSegment)
+
+	target, err := url.Parse(strings.TrimSuffix(config.Host, "/") + strings.TrimSuffix(config.APIPath, "/"))
+	if err != nil {
+		return h.sendError(c, err)
+	}
+	apiPath := target.Path + apiPrefix
+	target.Path = apiPath + "/" + path
+	target.RawQuery = string(c.Request().URI().QueryString())
+
+	ctx, cancel := context.WithCancel(c.UserContext())
+	req, err := http.NewRequestWithContext(ctx, c.Method(), target.String(), bytes.NewReader(c.Body()))
+	if err != nil {
+		cancel()
+		return h.sendError(c, err)
+	}
+	c.Request().Header.VisitAll(func(key, value []byte) {
+		req.Header.Add(string(key), string(value))
+	})
+	for _, header := range hopHeaders {
+		req.Header.Del(header)
+	}
+	// The transport only adds cluster credentials when none are present
+	req.Header.Del(fiber.HeaderAuthorization)
+	req.Header.Del(fiber.HeaderCookie)
+	if cookies := proxyCookies(string(c.Request().Header.Peek(fiber.HeaderCookie))); cookies != "" {
+		req.Header.Set(fiber.HeaderCookie, cookies)
+	}
+	req.Header.Del(fiber.HeaderHost)
+	req.Header.Set("X-Forwarded-Prefix", prefix)
+	req.Header.Set(fiber.HeaderXForwardedHost, c.Hostname())
+	req.Header.Set(fiber.HeaderXForwardedProto, c.Protocol())
+
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		cancel()
+		h.requestLogger(c).Warn("service proxy request failed", "context", contextName, "namespace", namespace, "service", service, "error", err)
+		return h.sendError(c, err)
+	}
+
+	for _, header := range hopHeaders {
+		resp.Header.Del(header)
+	}
+	body, err := rewriteHTMLLinks(resp, apiPath, prefix)
+	if err != nil {
+		resp.Body.Close()
+		cancel()
+		return h.sendError(c, err)
+	}
+	for key, values := range resp.Header {
+		switch {
+		case strings.HasPrefix(key, "Access-Control-"),
+			key == fiber.HeaderContentSecurityPolicy,
+			key == fiber.HeaderContentSecurityPolicyReportOnly:
+			// The service must not loosen the sandbox or webk9's CORS policy
+			continue
+		}
+		switch key {
+		case fiber.HeaderContentLength:
+			// Set from the body stream below
+		case fiber.HeaderLocation:
+			c.Set(key, rewriteLocation(values[0], target, apiPrefix, prefix))
+		case fiber.HeaderSetCookie:
+			for _, cookie := range values {
+				c.Response().Header.Add(key, rewriteCookie(cookie, prefix))
+			}
+		default:
+			for _, value := range values {
+				c.Response().Header.Add(key, value)
+			}
+		}
+	}
+
+	c.Set(fiber.HeaderContentSecurityPolicy, proxySandbox)
+
+	c.Status(resp.StatusCode)
+	c.Context().SetBodyStream(&cancelOnCloseReader{ReadCloser: body, cancel: cancel}, int(resp.ContentLength))
+	return nil
+}
+
+// rewriteLocation maps a redirect from the service or the API server proxy
+// back under prefix. Redirects to other hosts are left alone.
+func rewriteLocation(location string, target *url.URL, apiPrefix string, prefix string) string {
+	u, err := url.Parse(location)
+	if err != nil {
+		return location
+	}
+	if u.Host != "" && u.Host != target.Host {
+		return location
+	}
+	if !strings.HasPrefix(u.Path, "/") {
+		return location
+	}
+	path := u.Path
+	if i := strings.Index(path, apiPrefix); i >= 0 {
+		path = path[i+len(apiPrefix):]
+	}
+	u.Scheme, u.Host, u.User = "", "", nil
+	u.Path = prefix + path
+	u.RawPath = ""
+	return u.String()
+}
+
+// rewriteHTMLLinks maps the API server proxy paths in an HTML response back
+// under prefix. The API server rewrites root-relative links of proxied HTML
+// into paths under its own proxy subresource, which webk9 does not serve.
+// The returned body replaces resp.Body; resp.ContentLength and the encoding
+// headers are updated to match.
+func rewriteHTMLLinks(resp *http.Response, apiPath string, prefix string) (io.ReadCloser, error) {
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get(fiber.HeaderContentType))
+	if mediaType != fiber.MIMETextHTML {
+		return resp.Body, nil
+	}
+	var reader io.Reader = resp.Body
+	decoded := false
+	switch resp.Header.Get(fiber.HeaderContentEncoding) {
+	case "", "identity":
+	case "gzip":
+		gz, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		reader, decoded = gz, true
+	default:
+		return resp.Body, nil
+	}
+
+	page, err := io.ReadAll(io.LimitReader(reader, proxyRewriteLimit+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(page) > proxyRewriteLimit {
+		if decoded {
+			// Already decoded, so the rest must be sent decoded as well
+			resp.Header.Del(fiber.HeaderContentEncoding)
+			resp.ContentLength = -1
+		}
+		return readCloser{Reader: io.MultiReader(bytes.NewReader(page), reader), Closer: resp.Body}, nil
+	}
+	resp.Body.Close()
+
+	page = bytes.ReplaceAll(page, []byte(apiPath), []byte(prefix))
+	resp.Header.Del(fiber.HeaderContentEncoding)
+	resp.ContentLength = int64(len(page))
+	return io.NopCloser(bytes.NewReader(page)), nil
+}
+
+// readCloser joins a reader with the closer of the stream it reads from
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// proxyCookies selects the cookies of a Cookie header that were set by
+// proxied services and restores their original names
+func proxyCookies(header string) string {
+	var kept []string
+	for _, cookie := range strings.Split(header, ";") {
+		if name, ok := strings.CutPrefix(strings.TrimSpace(cookie), proxyCookiePrefix); ok {
+			kept = append(kept, name)
+		}
+	}
+	return strings.Join(kept, "; ")
+}
+
+// rewriteCookie renames a cookie with proxyCookiePrefix, scopes its Path
+// under prefix and drops its Domain, which would refer to the service
+// rather than webk9
+func rewriteCookie(cookie string, prefix string) string {
+	parts := strings.Split(cookie, ";")
+	hasPath := false
+	kept := []string{proxyCookiePrefix + strings.TrimSpace(parts[0])}
+	for _, part := range parts[1:] {
+		name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch strings.ToLower(name) {
+		case "domain":
+			continue
+		case "path":
+			hasPath = true
+			if !strings.HasPrefix(value, "/") {
+				value = "/"
+			}
+			part = " Path=" + prefix + strings.TrimSuffix(value, "/")
+			if value == "/" {
+				part = " Path=" + prefix + "/"
+			}
+		}
+		kept = append(kept, part)
+	}
+	if !hasPath {
+		kept = append(kept, " Path="+prefix+"/")
+	}
+	return strings.Join(kept, ";")
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+
+	"github.com/binodta/web-k9/backend/pkg/recording"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/websocket/v2"
+)
+
+// DefaultRecordingUserHeader is the header read for the user of a recorded
+// session when none is configured
+const DefaultRecordingUserHeader = "X-Forwarded-User"
+
+// startRecording starts recording an exec session, or returns nil when
+// recording is disabled or the recording cannot be created
+func (h *Handler) startRecording(c *websocket.Conn, namespace string, pod string, container string, command []string) *recording.Recording {
+	if h.Options.Recordings == nil {
+		return nil
+	}
+	header := h.Options.RecordingUserHeader
+	if header == "" {
+		header = DefaultRecordingUserHeader
+	}
+
+	rec, err := h.Options.Recordings.Start(recording.Metadata{
+		User:       c.Headers(header),
+		RemoteAddr: c.RemoteAddr().String(),
+		Context:    h.K8sManager.SelectedContext,
+		Namespace:  namespace,
+		Pod:        pod,
+		Container:  container,
+		Command:    command,
+	}, 80, 24)
+	if err != nil {
+		_, logger := h.wsContext(c)
+		logger.Error("failed to start session recording", "error", err)
+		return nil
+	}
+	return rec
+}
+
+// stopRecording closes a recording started by startRecording
+func (h *Handler) stopRecording(rec *recording.Recording, logger *slog.Logger) {
+	if rec == nil {
+		return
+	}
+	if err := rec.Close(); err != nil {
+		logger.Error("failed to finish session recording", "recording", rec.ID(), "error", err)
+		return
+	}
+	logger.Info("recorded exec session", "recording", rec.ID())
+}
+
+// ListRecordings returns the metadata of recorded exec sessions, newest first
+func (h *Handler) ListRecordings(c *fiber.Ctx) error {
+	if h.Options.Recordings == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "session recording is not enabled"})
+	}
+	recordings, err := h.Options.Recordings.List()
+	if err != nil {
+		return h.sendError(c, err)
+	}
+	return c.JSON(fiber.Map{"recordings": recordings})
+}
+
+// GetRecording streams a recorded session as an asciicast v2 file, suitable
+// for asciinema-player
+func (h *Handler) GetRecording(c *fiber.Ctx) error {
+	if h.Options.Recordings == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "session recording is not enabled"})
+	}
+	id := c.Params("id")
+	f, err := h.Options.Recordings.Open(id)
+	if errors.Is(err, recording.ErrNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return h.sendError(c, err)
+	}
+
+	c.Set(fiber.HeaderContentType, "application/x-asciicast")
+	if c.QueryBool("download") {
+		c.Attachment(id + ".cast")
+	}
+	return c.SendStream(f)
+}
@@ -1,8 +1,8 @@
 package handlers
 
 import (
-	"context"
 	"fmt"
+	"strconv"
 
 	"github.com/gofiber/fiber/v2"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -14,6 +14,9 @@ func (h *Handler) ListResources(c *fiber.Ctx) error {
 	if h.K8sManager.Clientset == nil {
 		return c.Status(fiber.StatusPreconditionFailed).JSON(fiber.Map{"error": "kubeconfig not loaded"})
 	}
+	ctx, cancel := h.requestContext(c)
+	defer cancel()
+
 	resourceType := c.Params("type")
 	namespace := c.Query("namespace", "default")
 	labelSelector := c.Query("labelSelector", "")
@@ -29,79 +32,60 @@ func (h *Handler) ListResources(c *fiber.Ctx) error {
 
 	switch resourceType {
 	case "pods":
-		list, err = h.K8sManager.Clientset.CoreV1().Pods(namespace).List(context.Background(), opts)
+		list, err = h.K8sManager.Clientset.CoreV1().Pods(namespace).List(ctx, opts)
 	case "deployments":
-		list, err = h.K8sManager.Clientset.AppsV1().Deployments(namespace).List(context.Background(), opts)
+		list, err = h.K8sManager.Clientset.AppsV1().Deployments(namespace).List(ctx, opts)
 	case "services":
-		list, err = h.K8sManager.Clientset.CoreV1().Services(namespace).List(context.Background(), opts)
+		list, err = h.K8sManager.Clientset.CoreV1().Services(namespace).List(ctx, opts)
 	case "statefulsets":
-		list, err = h.K8sManager.Clientset.AppsV1().StatefulSets(namespace).List(context.Background(), opts)
+		list, err = h.K8sManager.Clientset.AppsV1().StatefulSets(namespace).List(ctx, opts)
 	case "namespaces", "ns":
-		list, err = h.K8sManager.Clientset.CoreV1().Namespaces().List(context.Background(), opts)
+		list, err = h.K8sManager.Clientset.CoreV1().Namespaces().List(ctx, opts)
 	case "nodes", "no":
-		list, err = h.K8sManager.Clientset.CoreV1().Nodes().List(context.Background(), opts)
+		list, err = h.K8sManager.Clientset.CoreV1().Nodes().List(ctx, opts)
 	case "configmaps", "cm":
-		list, err = h.K8sManager.Clientset.CoreV1().ConfigMaps(namespace).List(context.Background(), opts)
+		list, err = h.K8sManager.Clientset.CoreV1().ConfigMaps(namespace).List(ctx, opts)
 	case "secrets", "sec":
-		list, err = h.K8sManager.Clientset.CoreV1().Secrets(namespace).List(context.Background(), opts)
+		list, err = h.K8sManager.Clientset.CoreV1().Secrets(namespace).List(ctx, opts)
 	case "ingresses", "ing":
-		list, err = h.K8sManager.Clientset.NetworkingV1().Ingresses(namespace).List(context.Background(), opts)
+		list, err = h.K8sManager.Clientset.NetworkingV1().Ingresses(namespace).List(ctx, opts)
 	case "persistentvolumes", "pv":
-		list, err = h.K8sManager.Clientset.CoreV1().PersistentVolumes().List(context.Background(), opts)
+		list, err = h.K8sManager.Clientset.CoreV1().PersistentVolumes().List(ctx, opts)
 	case "persistentvolumeclaims", "pvc":
-		list, err = h.K8sManager.Clientset.CoreV1().PersistentVolumeClaims(namespace).List(context.Background(), opts)
+		list, err = h.K8sManager.Clientset.CoreV1().PersistentVolumeClaims(namespace).List(ctx, opts)
 	default:
 		return c.Status(404).JSON(fiber.Map{"error": "unsupported resource type"})
 	}
 
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		return h.sendError(c, err)
 	}
 
 	return c.JSON(list)
 }
 
-// GetResource returns the full detail of a specific resource
+// GetResource returns the full detail of a specific resource. Any type known
+// to the cluster can be read, including custom resources, by plural,
+// singular, short or qualified name.
 func (h *Handler) GetResource(c *fiber.Ctx) error {
 	if h.K8sManager.Clientset == nil {
 		return c.Status(fiber.StatusPreconditionFailed).JSON(fiber.Map{"error": "kubeconfig not loaded"})
 	}
+	ctx, cancel := h.requestContext(c)
+	defer cancel()
+
 	resourceType := c.Params("type")
 	name := c.Params("name")
 	namespace := c.Query("namespace", "default")
 
-	var resource interface{}
-	var err error
-
-	switch resourceType {
-	case "pods":
-		resource, err = h.K8sManager.Clientset.CoreV1().Pods(namespace).Get(context.Background(), name, metav1.GetOptions{})
-	case "deployments":
-		resource, err = h.K8sManager.Clientset.AppsV1().Deployments(namespace).Get(context.Background(), name, metav1.GetOptions{})
-	case "services":
-		resource, err = h.K8sManager.Clientset.CoreV1().Services(namespace).Get(context.Background(), name, metav1.GetOptions{})
-	case "statefulsets":
-		resource, err = h.K8sManager.Clientset.AppsV1().StatefulSets(namespace).Get(context.Background(), name, metav1.GetOptions{})
-	case "namespaces", "ns":
-		resource, err = h.K8sManager.Clientset.CoreV1().Namespaces().Get(context.Background(), name, metav1.GetOptions{})
-	case "nodes", "no":
-		resource, err = h.K8sManager.Clientset.CoreV1().Nodes().Get(context.Background(), name, metav1.GetOptions{})
-	case "configmaps", "cm":
-		resource, err = h.K8sManager.Clientset.CoreV1().ConfigMaps(namespace).Get(context.Background(), name, metav1.GetOptions{})
-	case "secrets", "sec":
-		resource, err = h.K8sManager.Clientset.CoreV1().Secrets(namespace).Get(context.Background(), name, metav1.GetOptions{})
-	case "ingresses", "ing":
-		resource, err = h.K8sManager.Clientset.NetworkingV1().Ingresses(namespace).Get(context.Background(), name, metav1.GetOptions{})
-	case "persistentvolumes", "pv":
-		resource, err = h.K8sManager.Clientset.CoreV1().PersistentVolumes().Get(context.Background(), name, metav1.GetOptions{})
-	case "persistentvolumeclaims", "pvc":
-		resource, err = h.K8sManager.Clientset.CoreV1().PersistentVolumeClaims(namespace).Get(context.Background(), name, metav1.GetOptions{})
-	default:
-		return c.Status(404).JSON(fiber.Map{"error": "unsupported resource type"})
+	res, err := h.K8sManager.ResolveResource(ctx, resourceType)
+	if err != nil {
+		return h.sendResolveError(c, err)
 	}
 
+	resource, err := h.K8sManager.ResourceInterface(res, namespace).Get(ctx, name, metav1.GetOptions{})
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		return h.sendError(c, err)
 	}
 
 	return c.JSON(resource)
@@ -112,6 +96,9 @@ func (h *Handler) GetResourceYaml(c *fiber.Ctx) error {
 	if h.K8sManager.Clientset == nil {
 		return c.Status(fiber.StatusPreconditionFailed).JSON(fiber.Map{"error": "kubeconfig not loaded"})
 	}
+	ctx, cancel := h.requestContext(c)
+	defer cancel()
+
 	resourceType := c.Params("type")
 	name := c.Params("name")
 	namespace := c.Query("namespace", "default")
@@ -121,38 +108,38 @@ func (h *Handler) GetResourceYaml(c *fiber.Ctx) error {
 
 	switch resourceType {
 	case "pods":
-		resource, err = h.K8sManager.Clientset.CoreV1().Pods(namespace).Get(context.Background(), name, metav1.GetOptions{})
+		resource, err = h.K8sManager.Clientset.CoreV1().Pods(namespace).Get(ctx, name, metav1.GetOptions{})
 	case "deployments":
-		resource, err = h.K8sManager.Clientset.AppsV1().Deployments(namespace).Get(context.Background(), name, metav1.GetOptions{})
+		resource, err = h.K8sManager.Clientset.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
 	case "services":
-		resource, err = h.K8sManager.Clientset.CoreV1().Services(namespace).Get(context.Background(), name, metav1.GetOptions{})
+		resource, err = h.K8sManager.Clientset.CoreV1().Services(namespace).Get(ctx, name, metav1.GetOptions{})
 	case "statefulsets":
-		resource, err = h.K8sManager.Clientset.AppsV1().StatefulSets(namespace).Get(context.Background(), name, metav1.GetOptions{})
+		resource, err = h.K8sManager.Clientset.AppsV1().StatefulSets(namespace).Get(ctx, name, metav1.GetOptions{})
 	case "namespaces", "ns":
-		resource, err = h.K8sManager.Clientset.CoreV1().Namespaces().Get(context.Background(), name, metav1.GetOptions{})
+		resource, err = h.K8sManager.Clientset.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
 	case "nodes", "no":
-		resource, err = h.K8sManager.Clientset.CoreV1().Nodes().Get(context.Background(), name, metav1.GetOptions{})
+		resource, err = h.K8sManager.Clientset.CoreV1().Nodes().Get(ctx, name, metav1.GetOptions{})
 	case "configmaps", "cm":
-		resource, err = h.K8sManager.Clientset.CoreV1().ConfigMaps(namespace).Get(context.Background(), name, metav1.GetOptions{})
+		resource, err = h.K8sManager.Clientset.CoreV1().ConfigMaps(namespace).Get(ctx, name, metav1.GetOptions{})
 	case "secrets", "sec":
-		resource, err = h.K8sManager.Clientset.CoreV1().Secrets(namespace).Get(context.Background(), name, metav1.GetOptions{})
+		resource, err = h.K8sManager.Clientset.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
 	case "ingresses", "ing":
-		resource, err = h.K8sManager.Clientset.NetworkingV1().Ingresses(namespace).Get(context.Background(), name, metav1.GetOptions{})
+		resource, err = h.K8sManager.Clientset.NetworkingV1().Ingresses(namespace).Get(ctx, name, metav1.GetOptions{})
 	case "persistentvolumes", "pv":
-		resource, err = h.K8sManager.Clientset.CoreV1().PersistentVolumes().Get(context.Background(), name, metav1.GetOptions{})
+		resource, err = h.K8sManager.Clientset.CoreV1().PersistentVolumes().Get(ctx, name, metav1.GetOptions{})
 	case "persistentvolumeclaims", "pvc":
-		resource, err = h.K8sManager.Clientset.CoreV1().PersistentVolumeClaims(namespace).Get(context.Background(), name, metav1.GetOptions{})
+		resource, err = h.K8sManager.Clientset.CoreV1().PersistentVolumeClaims(namespace).Get(ctx, name, metav1.GetOptions{})
 	default:
 		return c.Status(404).JSON(fiber.Map{"error": "unsupported resource type"})
 	}
 
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		return h.sendError(c, err)
 	}
 
 	y, err := yaml.Marshal(resource)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		return h.sendError(c, err)
 	}
 
 	return c.SendString(string(y))
@@ -163,55 +150,94 @@ func (h *Handler) UpdateResourceYaml(c *fiber.Ctx) error {
 	return c.Status(501).JSON(fiber.Map{"error": "not implemented yet"})
 }
 
-// DeleteResource deletes a resource
+// DeleteResource deletes a resource. Types are resolved as in GetResource.
+// gracePeriodSeconds overrides the object's grace period, propagationPolicy
+// (Foreground, Background or Orphan) controls how dependents are removed,
+// force=true deletes immediately with a zero grace period, as for pods stuck
+// on an unreachable node, and dryRun=true validates without deleting.
 func (h *Handler) DeleteResource(c *fiber.Ctx) error {
 	if h.K8sManager.Clientset == nil {
 		return c.Status(fiber.StatusPreconditionFailed).JSON(fiber.Map{"error": "kubeconfig not loaded"})
 	}
+	opts, err := parseDeleteOptions(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
 	resourceType := c.Params("type")
 	name := c.Params("name")
 	namespace := c.Query("namespace", "default")
 
-	var err error
-	switch resourceType {
-	case "pods":
-		err = h.K8sManager.Clientset.CoreV1().Pods(namespace).Delete(context.Background(), name, metav1.DeleteOptions{})
-	case "deployments":
-		err = h.K8sManager.Clientset.AppsV1().Deployments(namespace).Delete(context.Background(), name, metav1.DeleteOptions{})
-	case "services":
-		err = h.K8sManager.Clientset.CoreV1().Services(namespace).Delete(context.Background(), name, metav1.DeleteOptions{})
-	case "statefulsets":
-		err = h.K8sManager.Clientset.AppsV1().StatefulSets(namespace).Delete(context.Background(), name, metav1.DeleteOptions{})
-	case "namespaces", "ns":
-		err = h.K8sManager.Clientset.CoreV1().Namespaces().Delete(context.Background(), name, metav1.DeleteOptions{})
-	case "nodes", "no":
-		err = h.K8sManager.Clientset.CoreV1().Nodes().Delete(context.Background(), name, metav1.DeleteOptions{})
-	case "configmaps", "cm":
-		err = h.K8sManager.Clientset.CoreV1().ConfigMaps(namespace).Delete(context.Background(), name, metav1.DeleteOptions{})
-	case "secrets", "sec":
-		err = h.K8sManager.Clientset.CoreV1().Secrets(namespace).Delete(context.Background(), name, metav1.DeleteOptions{})
-	case "ingresses", "ing":
-		err = h.K8sManager.Clientset.NetworkingV1().Ingresses(namespace).Delete(context.Background(), name, metav1.DeleteOptions{})
-	case "persistentvolumes", "pv":
-		err = h.K8sManager.Clientset.CoreV1().PersistentVolumes().Delete(context.Background(), name, metav1.DeleteOptions{})
-	case "persistentvolumeclaims", "pvc":
-		err = h.K8sManager.Clientset.CoreV1().PersistentVolumeClaims(namespace).Delete(context.Background(), name, metav1.DeleteOptions{})
-	default:
-		return c.Status(404).JSON(fiber.Map{"error": "unsupported resource type"})
-	}
+	ctx, cancel := h.requestContext(c)
+	defer cancel()
 
+	res, err := h.K8sManager.ResolveResource(ctx, resourceType)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		return h.sendResolveError(c, err)
+	}
+
+	if err := h.K8sManager.ResourceInterface(res, namespace).Delete(ctx, name, opts); err != nil {
+		return h.sendError(c, err)
 	}
 
+	dryRun := len(opts.DryRun) > 0
+	h.requestLogger(c).Info("deleted resource", "type", res.GVR.Resource, "namespace", namespace, "name", name, "dry_run", dryRun)
+	if dryRun {
+		return c.JSON(fiber.Map{"message": "resource would be deleted (dry run)"})
+	}
 	return c.JSON(fiber.Map{"message": "resource deleted"})
 }
 
+// parseDeleteOptions reads the delete query parameters of DeleteResource
+func parseDeleteOptions(q queryReader) (metav1.DeleteOptions, error) {
+	var opts metav1.DeleteOptions
+
+	if v := q.Query("gracePeriodSeconds"); v != "" {
+		seconds, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || seconds < 0 {
+			return opts, fmt.Errorf("invalid gracePeriodSeconds %q: must be a non-negative integer", v)
+		}
+		opts.GracePeriodSeconds = &seconds
+	}
+
+	force, err := parseBoolQuery(q, "force", false)
+	if err != nil {
+		return opts, err
+	}
+	if force {
+		if opts.GracePeriodSeconds != nil && *opts.GracePeriodSeconds != 0 {
+			return opts, fmt.Errorf("force requires a gracePeriodSeconds of 0")
+		}
+		var immediate int64
+		opts.GracePeriodSeconds = &immediate
+	}
+
+	switch v := metav1.DeletionPropagation(q.Query("propagationPolicy")); v {
+	case "":
+	case metav1.DeletePropagationForeground, metav1.DeletePropagationBackground, metav1.DeletePropagationOrphan:
+		opts.PropagationPolicy = &v
+	default:
+		return opts, fmt.Errorf("invalid propagationPolicy %q: must be Foreground, Background or Orphan", v)
+	}
+
+	dryRun, err := parseBoolQuery(q, "dryRun", false)
+	if err != nil {
+		return opts, err
+	}
+	if dryRun {
+		opts.DryRun = []string{metav1.DryRunAll}
+	}
+	return opts, nil
+}
+
 // GetEvents returns events for a resource
 func (h *Handler) GetEvents(c *fiber.Ctx) error {
 	if h.K8sManager.Clientset == nil {
 		return c.Status(fiber.StatusPreconditionFailed).JSON(fiber.Map{"error": "kubeconfig not loaded"})
 	}
+	ctx, cancel := h.requestContext(c)
+	defer cancel()
+
 	resourceType := c.Params("type")
 	name := c.Params("name")
 	namespace := c.Query("namespace", "default")
@@ -247,12 +273,12 @@ func (h *Handler) GetEvents(c *fiber.Ctx) error {
 		fieldSelector = fmt.Sprintf("involvedObject.kind=%s,involvedObject.name=%s", kind, name)
 	}
 
-	events, err := h.K8sManager.Clientset.CoreV1().Events(namespace).List(context.Background(), metav1.ListOptions{
+	events, err := h.K8sManager.Clientset.CoreV1().Events(namespace).List(ctx, metav1.ListOptions{
 		FieldSelector: fieldSelector,
 	})
 
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		return h.sendError(c, err)
 	}
 
 	return c.JSON(events)
@@ -263,10 +289,12 @@ func (h *Handler) GetDiscovery(c *fiber.Ctx) error {
 	if h.K8sManager.Clientset == nil {
 		return c.Status(fiber.StatusPreconditionFailed).JSON(fiber.Map{"error": "kubeconfig not loaded"})
 	}
+	ctx, cancel := h.requestContext(c)
+	defer cancel()
 
-	resources, err := h.K8sManager.GetAPIResources()
+	resources, err := h.K8sManager.GetAPIResources(ctx)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		return h.sendError(c, err)
 	}
 
 	return c.JSON(resources)
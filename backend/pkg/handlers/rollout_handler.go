@@ -0,0 +1,274 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/binodta/web-k9/backend/pkg/k8s"
+	"github.com/binodta/web-k9/backend/pkg/metrics"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/websocket/v2"
+	"github.com/pmezard/go-difflib/difflib"
+	"k8s.io/apimachinery/pkg/watch"
+	"sigs.k8s.io/yaml"
+)
+
+// rolloutStatusMessage is sent by StreamRolloutStatus whenever the status changes
+type rolloutStatusMessage struct {
+	Message string `json:"message,omitempty"`
+	Done    bool   `json:"done"`
+	Error   string `json:"error,omitempty"`
+}
+
+// RolloutAction restarts a deployment, statefulset or daemonset, or pauses
+// or resumes a deployment, depending on the :action route parameter
+func (h *Handler) RolloutAction(c *fiber.Ctx) error {
+	if h.K8sManager.Clientset == nil {
+		return c.Status(fiber.StatusPreconditionFailed).JSON(fiber.Map{"error": "kubeconfig not loaded"})
+	}
+	kind, err := k8s.RolloutKind(c.Params("type"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	name := c.Params("name")
+	namespace := c.Query("namespace", "default")
+	action := c.Params("action")
+
+	ctx, cancel := h.requestContext(c)
+	defer cancel()
+
+	switch action {
+	case "restart":
+		err = h.K8sManager.RolloutRestart(ctx, namespace, kind, name)
+	case "pause", "resume":
+		if kind != "deployments" {
+			return c.Status(400).JSON(fiber.Map{"error": fmt.Sprintf("%s is only supported for deployments", action)})
+		}
+		err = h.K8sManager.SetDeploymentPaused(ctx, namespace, name, action == "pause")
+	default:
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "unknown rollout action: " + action})
+	}
+	if err != nil {
+		return h.sendError(c, err)
+	}
+
+	h.requestLogger(c).Info("rollout action", "action", action, "type", kind, "namespace", namespace, "name", name)
+	return c.JSON(fiber.Map{"action": action, "kind": kind, "name": name, "namespace": namespace})
+}
+
+// RolloutHistory lists the revisions of a deployment, statefulset or daemonset
+func (h *Handler) RolloutHistory(c *fiber.Ctx) error {
+	if h.K8sManager.Clientset == nil {
+		return c.Status(fiber.StatusPreconditionFailed).JSON(fiber.Map{"error": "kubeconfig not loaded"})
+	}
+	kind, err := k8s.RolloutKind(c.Params("type"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	ctx, cancel := h.requestContext(c)
+	defer cancel()
+
+	history, err := h.K8sManager.RolloutHistory(ctx, c.Query("namespace", "default"), kind, c.Params("name"))
+	if err != nil {
+		return h.sendError(c, err)
+	}
+	return c.JSON(fiber.Map{"revisions": history})
+}
+
+// RolloutDiff returns a unified diff of the pod templates of two revisions.
+// to defaults to the current revision and from to the revision before it.
+func (h *Handler) RolloutDiff(c *fiber.Ctx) error {
+	if h.K8sManager.Clientset == nil {
+		return c.Status(fiber.StatusPreconditionFailed).JSON(fiber.Map{"error": "kubeconfig not loaded"})
+	}
+	kind, err := k8s.RolloutKind(c.Params("type"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	from, err := parseRevisionQuery(c, "from")
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	to, err := parseRevisionQuery(c, "to")
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	ctx, cancel := h.requestContext(c)
+	defer cancel()
+
+	history, err := h.K8sManager.RolloutHistory(ctx, c.Query("namespace", "default"), kind, c.Params("name"))
+	if err != nil {
+		return h.sendError(c, err)
+	}
+
+	toIndex := -1
+	for i, r := range history {
+		if (to == 0 && r.Current) || (to != 0 && r.Revision == to) {
+			toIndex = i
+		}
+	}
+	if toIndex < 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": fmt.Sprintf("revision %d not found", to)})
+	}
+	fromIndex := toIndex - 1
+	if from != 0 {
+		fromIndex = -1
+		for i, r := range history {
+			if r.Revision == from {
+				fromIndex = i
+			}
+		}
+	}
+	if fromIndex < 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "no revision to compare with"})
+	}
+
+	a, err := yaml.Marshal(history[fromIndex].Template)
+	if err != nil {
+		return h.sendError(c, err)
+	}
+	b, err := yaml.Marshal(history[toIndex].Template)
+	if err != nil {
+		return h.sendError(c, err)
+	}
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(a)),
+		B:        difflib.SplitLines(string(b)),
+		FromFile: fmt.Sprintf("revision %d", history[fromIndex].Revision),
+		ToFile:   fmt.Sprintf("revision %d", history[toIndex].Revision),
+		Context:  3,
+	})
+	if err != nil {
+		return h.sendError(c, err)
+	}
+	return c.JSON(fiber.Map{
+		"from": history[fromIndex].Revision,
+		"to":   history[toIndex].Revision,
+		"diff": diff,
+	})
+}
+
+// RolloutUndo rolls a workload back to a revision. The request body is
+//
+//	{"revision": 2}
+//
+// where 0 or an empty body means the revision before the current one.
+func (h *Handler) RolloutUndo(c *fiber.Ctx) error {
+	if h.K8sManager.Clientset == nil {
+		return c.Status(fiber.StatusPreconditionFailed).JSON(fiber.Map{"error": "kubeconfig not loaded"})
+	}
+	kind, err := k8s.RolloutKind(c.Params("type"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	var body struct {
+		Revision int64 `json:"revision"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&body); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "invalid body"})
+		}
+	}
+	name := c.Params("name")
+	namespace := c.Query("namespace", "default")
+
+	ctx, cancel := h.requestContext(c)
+	defer cancel()
+
+	revision, err := h.K8sManager.RolloutUndo(ctx, namespace, kind, name, body.Revision)
+	if err != nil {
+		return h.sendError(c, err)
+	}
+	h.requestLogger(c).Info("rolled back", "type", kind, "namespace", namespace, "name", name, "revision", revision)
+	return c.JSON(fiber.Map{"kind": kind, "name": name, "namespace": namespace, "revision": revision})
+}
+
+// StreamRolloutStatus handles WebSocket connections that follow a rollout
+// like kubectl rollout status, sending a message whenever the status changes
+// until the rollout completes or fails
+func (h *Handler) StreamRolloutStatus(c *websocket.Conn) {
+	defer metrics.TrackWebSocket("rollout-status")()
+	if h.K8sManager.Clientset == nil {
+		c.WriteJSON(fiber.Map{"error": "kubeconfig not loaded"})
+		return
+	}
+	kind, err := k8s.RolloutKind(c.Query("type"))
+	if err != nil {
+		c.WriteJSON(fiber.Map{"error": err.Error()})
+		return
+	}
+	name := c.Query("name")
+	namespace := c.Query("namespace", "default")
+	timeout, err := parseDurationQuery(c, "timeout", defaultWaitTimeout)
+	if err != nil {
+		c.WriteJSON(fiber.Map{"error": err.Error()})
+		return
+	}
+
+	baseCtx, logger := h.wsContext(c)
+	ctx, cancel := context.WithTimeout(baseCtx, timeout)
+	defer cancel()
+	cancelOnClose(c, cancel)
+
+	// A watch on a missing workload would wait silently until the timeout
+	if _, err := h.K8sManager.GetWorkload(ctx, namespace, kind, name); err != nil {
+		writeWSError(c, err)
+		return
+	}
+
+	last := ""
+	for ctx.Err() == nil {
+		w, err := h.K8sManager.WatchWorkload(ctx, namespace, kind, name)
+		if err != nil {
+			writeWSError(c, err)
+			return
+		}
+		for event := range w.ResultChan() {
+			var msg rolloutStatusMessage
+			switch event.Type {
+			case watch.Deleted:
+				msg = rolloutStatusMessage{Done: true, Error: fmt.Sprintf("%s %s was deleted", kind, name)}
+			case watch.Error:
+				msg = rolloutStatusMessage{Done: true, Error: fmt.Sprintf("watch failed: %v", event.Object)}
+			default:
+				message, done, err := k8s.RolloutStatus(event.Object)
+				msg = rolloutStatusMessage{Message: message, Done: done || err != nil}
+				if err != nil {
+					msg.Error = err.Error()
+				}
+			}
+			key := msg.Message + msg.Error
+			if key == last && !msg.Done {
+				continue
+			}
+			last = key
+			if err := c.WriteJSON(msg); err != nil || msg.Done {
+				w.Stop()
+				return
+			}
+		}
+		w.Stop()
+	}
+
+	if ctx.Err() == context.DeadlineExceeded {
+		logger.Info("rollout status timed out", "type", kind, "namespace", namespace, "name", name)
+		c.WriteJSON(rolloutStatusMessage{Done: true, Error: "timed out waiting for the rollout to finish"})
+	}
+}
+
+// parseRevisionQuery reads an optional revision number
+func parseRevisionQuery(c *fiber.Ctx, key string) (int64, error) {
+	v := strings.TrimSpace(c.Query(key))
+	if v == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid %s %q: must be a revision number", key, v)
+	}
+	return n, nil
+}
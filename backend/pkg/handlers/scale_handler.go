@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/binodta/web-k9/backend/pkg/k8s"
+	"github.com/gofiber/fiber/v2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// defaultWaitTimeout bounds how long streaming operations wait for a
+	// resource to converge
+	defaultWaitTimeout = 5 * time.Minute
+	// waitPollInterval is how often a converging resource is checked
+	waitPollInterval = 2 * time.Second
+)
+
+// scaleProgress is one line of the NDJSON stream returned while waiting
+// for a scaled resource to converge
+type scaleProgress struct {
+	Replicas        int32  `json:"replicas"`
+	CurrentReplicas int32  `json:"currentReplicas"`
+	Pods            int    `json:"pods"`
+	ReadyPods       int    `json:"readyPods"`
+	Done            bool   `json:"done"`
+	Error           string `json:"error,omitempty"`
+}
+
+// ScaleResource sets the replicas of any resource with a scale subresource,
+// including custom resources, and returns the resulting autoscaling/v1
+// Scale. The request body is
+//
+//	{"replicas": 3}
+//
+// With wait=true the response is an NDJSON stream of scaleProgress lines,
+// ending once the ready pods match the requested replicas or after timeout
+// (default 5m).
+func (h *Handler) ScaleResource(c *fiber.Ctx) error {
+	if h.K8sManager.Clientset == nil {
+		return c.Status(fiber.StatusPreconditionFailed).JSON(fiber.Map{"error": "kubeconfig not loaded"})
+	}
+	resourceType := c.Params("type")
+	name := c.Params("name")
+	namespace := c.Query("namespace", "default")
+
+	var body struct {
+		Replicas *int32 `json:"replicas"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid body"})
+	}
+	if body.Replicas == nil || *body.Replicas < 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "replicas must be zero or more"})
+	}
+	wait, err := parseBoolQuery(c, "wait", false)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	timeout, err := parseDurationQuery(c, "timeout", defaultWaitTimeout)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	ctx, cancel := h.requestContext(c)
+	defer cancel()
+
+	res, err := h.K8sManager.ResolveResource(ctx, resourceType)
+	if err != nil {
+		return h.sendResolveError(c, err)
+	}
+
+	scale, err := h.K8sManager.SetScale(ctx, res, namespace, name, *body.Replicas)
+	if err != nil {
+		return h.sendError(c, err)
+	}
+	h.requestLogger(c).Info("scaled resource", "type", res.GVR.Resource, "namespace", namespace, "name", name, "replicas", *body.Replicas)
+	if !wait {
+		return c.JSON(scale)
+	}
+
+	// The stream is written after this handler returns
+	waitCtx, waitCancel := context.WithTimeout(c.UserContext(), timeout)
+	c.Set(fiber.HeaderContentType, "application/x-ndjson")
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer waitCancel()
+		enc := json.NewEncoder(w)
+		ticker := time.NewTicker(waitPollInterval)
+		defer ticker.Stop()
+
+		for {
+			progress := h.scaleProgress(waitCtx, res, namespace, name)
+			if waitCtx.Err() != nil {
+				progress.Done = true
+				progress.Error = "timed out waiting for replicas to become ready"
+			}
+			if enc.Encode(progress) != nil || w.Flush() != nil || progress.Done {
+				return
+			}
+			select {
+			case <-waitCtx.Done():
+			case <-ticker.C:
+			}
+		}
+	})
+	return nil
+}
+
+// scaleProgress compares the requested replicas of a scalable resource with
+// its pods, found through the selector published by the scale subresource
+func (h *Handler) scaleProgress(ctx context.Context, res k8s.ResolvedResource, namespace string, name string) scaleProgress {
+	scale, err := h.K8sManager.GetScale(ctx, res, namespace, name)
+	if err != nil {
+		return scaleProgress{Done: ctx.Err() == nil, Error: err.Error()}
+	}
+	progress := scaleProgress{Replicas: scale.Spec.Replicas, CurrentReplicas: scale.Status.Replicas}
+
+	if scale.Status.Selector == "" {
+		// Without a selector, only the controller's own count is available
+		progress.Done = scale.Status.Replicas == scale.Spec.Replicas
+		return progress
+	}
+	pods, err := h.K8sManager.Clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: scale.Status.Selector})
+	if err != nil {
+		progress.Error = err.Error()
+		return progress
+	}
+	for i := range pods.Items {
+		if pods.Items[i].DeletionTimestamp != nil {
+			continue
+		}
+		progress.Pods++
+		if k8s.IsPodReady(&pods.Items[i]) {
+			progress.ReadyPods++
+		}
+	}
+	progress.Done = progress.CurrentReplicas == progress.Replicas &&
+		progress.Pods == int(progress.Replicas) &&
+		progress.ReadyPods == int(progress.Replicas)
+	return progress
+}
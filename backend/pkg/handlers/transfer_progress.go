@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/binodta/web-k9/backend/pkg/metrics"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/websocket/v2"
+)
+
+const (
+	// transferProgressInterval throttles progress updates for one transfer
+	transferProgressInterval = 250 * time.Millisecond
+	// transferRetention is how long the final state of a transfer is kept
+	// for clients that subscribe late
+	transferRetention = time.Minute
+)
+
+// transferProgress is a progress update for a file copy. Total is zero when
+// the size is not known up front.
+type transferProgress struct {
+	ID        string `json:"id"`
+	Direction string `json:"direction"`
+	Path      string `json:"path"`
+	File      string `json:"file,omitempty"`
+	Bytes     int64  `json:"bytes"`
+	Total     int64  `json:"total,omitempty"`
+	Done      bool   `json:"done"`
+	Error     string `json:"error,omitempty"`
+}
+
+// transferHub fans progress updates out to WebSocket subscribers, keyed by
+// the transfer ID chosen by the client
+type transferHub struct {
+	mu   sync.Mutex
+	subs map[string]map[chan transferProgress]struct{}
+	last map[string]transferProgress
+}
+
+func newTransferHub() *transferHub {
+	return &transferHub{
+		subs: make(map[string]map[chan transferProgress]struct{}),
+		last: make(map[string]transferProgress),
+	}
+}
+
+// subscribe returns a channel of updates for id, primed with the latest one
+func (t *transferHub) subscribe(id string) (chan transferProgress, func()) {
+	ch := make(chan transferProgress, 16)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.subs[id] == nil {
+		t.subs[id] = make(map[chan transferProgress]struct{})
+	}
+	t.subs[id][ch] = struct{}{}
+	if p, ok := t.last[id]; ok {
+		ch <- p
+	}
+	return ch, func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		delete(t.subs[id], ch)
+		if len(t.subs[id]) == 0 {
+			delete(t.subs, id)
+		}
+	}
+}
+
+// publish sends p to subscribers. Intermediate updates are dropped for slow
+// subscribers; the final update replaces whatever is queued.
+func (t *transferHub) publish(p transferProgress) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.last[p.ID] = p
+	for ch := range t.subs[p.ID] {
+		select {
+		case ch <- p:
+		default:
+			if p.Done {
+				select {
+				case <-ch:
+				default:
+				}
+				ch <- p
+			}
+		}
+	}
+	if p.Done {
+		id := p.ID
+		time.AfterFunc(transferRetention, func() {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			if last, ok := t.last[id]; ok && last.Done {
+				delete(t.last, id)
+			}
+		})
+	}
+}
+
+// transferTracker reports the progress of one transfer. A nil tracker, used
+// when the client did not ask for progress, does nothing.
+type transferTracker struct {
+	hub *transferHub
+
+	mu       sync.Mutex
+	progress transferProgress
+	sentAt   time.Time
+}
+
+func (h *Handler) trackTransfer(id string, direction string, path string, total int64) *transferTracker {
+	if id == "" {
+		return nil
+	}
+	t := &transferTracker{
+		hub:      h.transfers,
+		progress: transferProgress{ID: id, Direction: direction, Path: path, Total: max(total, 0)},
+	}
+	t.hub.publish(t.progress)
+	return t
+}
+
+func (t *transferTracker) add(n int64) {
+	if t == nil || n == 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.progress.Bytes += n
+	if time.Since(t.sentAt) >= transferProgressInterval {
+		t.sentAt = time.Now()
+		t.hub.publish(t.progress)
+	}
+}
+
+func (t *transferTracker) setFile(name string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.progress.File = name
+}
+
+func (t *transferTracker) finish(err error) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.progress.Done {
+		return
+	}
+	t.progress.Done = true
+	if err != nil {
+		t.progress.Error = err.Error()
+	}
+	t.hub.publish(t.progress)
+}
+
+// progressReader counts the bytes read through it
+type progressReader struct {
+	r       io.Reader
+	tracker *transferTracker
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.tracker.add(int64(n))
+	return n, err
+}
+
+// StreamTransfer handles WebSocket connections that follow the progress of
+// a file copy started with the same transferId
+func (h *Handler) StreamTransfer(c *websocket.Conn) {
+	defer metrics.TrackWebSocket("transfer")()
+	id := c.Query("id")
+	if id == "" {
+		c.WriteJSON(fiber.Map{"error": "transfer id is required"})
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cancelOnClose(c, cancel)
+
+	updates, unsubscribe := h.transfers.subscribe(id)
+	defer unsubscribe()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case p := <-updates:
+			if err := c.WriteJSON(p); err != nil || p.Done {
+				return
+			}
+		}
+	}
+}
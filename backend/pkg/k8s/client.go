@@ -2,13 +2,23 @@ package k8s
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"sync"
+	"time"
 
+	"github.com/binodta/web-k9/backend/pkg/logging"
+	"github.com/binodta/web-k9/backend/pkg/metrics"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/version"
 	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
@@ -21,13 +31,26 @@ type ClientManager struct {
 	Config           *rest.Config
 	Clientset        *kubernetes.Clientset
 	MetricsClientset *metricsv1beta1.Clientset
+	DynamicClient    dynamic.Interface
+	RESTMapper       meta.RESTMapper
 	RawConfig        *api.Config
 	ConfigPath       string
 	SelectedContext  string
+	Logger           *slog.Logger
+
+	informersMu sync.Mutex
+	informers   map[int]registeredInformer
+	informerSeq int
+
+	nodeShellsMu sync.Mutex
+	nodeShells   map[string]nodeShellRef
+
+	contextsMu     sync.Mutex
+	contextClients map[string]contextClient
 }
 
-func NewClientManager() *ClientManager {
-	return &ClientManager{}
+func NewClientManager(logger *slog.Logger) *ClientManager {
+	return &ClientManager{Logger: logger}
 }
 
 // DiscoverKubeconfigs looks for potential kubeconfig files in ~/.kube/
@@ -64,7 +87,8 @@ func (cm *ClientManager) DiscoverKubeconfigs() ([]string, error) {
 
 // LoadConfig loads a specific kubeconfig file and context
 func (cm *ClientManager) LoadConfig(path string, context string) error {
-	fmt.Printf("DEBUG: Loading config path=%s, context=%s\n", path, context)
+	logger := cm.Logger.With("path", path)
+	logger.Debug("loading kubeconfig", "requested_context", context)
 
 	if path == "" {
 		return fmt.Errorf("kubeconfig path is empty")
@@ -72,7 +96,7 @@ func (cm *ClientManager) LoadConfig(path string, context string) error {
 
 	raw, err := clientcmd.LoadFromFile(path)
 	if err != nil {
-		fmt.Printf("DEBUG: Failed to load file: %v\n", err)
+		logger.Warn("failed to read kubeconfig", "error", err)
 		return fmt.Errorf("failed to load kubeconfig file %s: %w", path, err)
 	}
 
@@ -87,14 +111,15 @@ func (cm *ClientManager) LoadConfig(path string, context string) error {
 
 	if activeContext != "" {
 		if _, ok := raw.Contexts[activeContext]; !ok {
-			fmt.Printf("DEBUG: Context %q not found in %s. Falling back to first available context.\n", activeContext, path)
 			// Fallback to first available context
 			if len(raw.Contexts) > 0 {
+				missing := activeContext
 				for first := range raw.Contexts {
 					activeContext = first
 					break
 				}
-				fmt.Printf("DEBUG: Using fallback context: %s\n", activeContext)
+				logger.Warn("context not found, falling back to first available context",
+					"requested_context", missing, "fallback_context", activeContext)
 			} else {
 				return fmt.Errorf("no contexts found in config %s", path)
 			}
@@ -107,7 +132,7 @@ func (cm *ClientManager) LoadConfig(path string, context string) error {
 			break
 		}
 		raw.CurrentContext = activeContext
-		fmt.Printf("DEBUG: No context specified, using first available: %s\n", activeContext)
+		logger.Debug("no context specified, using first available", "context", activeContext)
 	} else {
 		return fmt.Errorf("no contexts found in config %s", path)
 	}
@@ -116,30 +141,43 @@ func (cm *ClientManager) LoadConfig(path string, context string) error {
 	clientConfig := clientcmd.NewNonInteractiveClientConfig(*raw, raw.CurrentContext, &clientcmd.ConfigOverrides{}, nil)
 	config, err := clientConfig.ClientConfig()
 	if err != nil {
-		fmt.Printf("DEBUG: Failed to get REST config for context %q: %v\n", raw.CurrentContext, err)
+		logger.Warn("failed to build REST config", "context", raw.CurrentContext, "error", err)
 		return fmt.Errorf("failed to get client config for context %q: %w", raw.CurrentContext, err)
 	}
+	config.Wrap(metrics.InstrumentTransport(raw.CurrentContext))
+	config.Wrap(logging.Transport(cm.Logger, raw.CurrentContext))
 
 	clientset, err := kubernetes.NewForConfig(config)
 	if err != nil {
 		return fmt.Errorf("failed to create clientset: %w", err)
 	}
 
+	dynamicClient, mapper, err := newDynamicClients(config)
+	if err != nil {
+		return err
+	}
+
 	metricsClientset, err := metricsv1beta1.NewForConfig(config)
 	if err != nil {
 		// Log but don't fail, metrics might not be available
-		fmt.Printf("DEBUG: Metrics clientset warning: %v\n", err)
+		logger.Warn("metrics clientset unavailable", "error", err)
 	}
 
 	cm.Config = config
 	cm.Clientset = clientset
 	cm.MetricsClientset = metricsClientset
+	cm.DynamicClient = dynamicClient
+	cm.RESTMapper = mapper
 	cm.RawConfig = raw.DeepCopy()
 	cm.ConfigPath = path
 	cm.SelectedContext = raw.CurrentContext
 
-	fmt.Printf("DEBUG: Successfully loaded config. Context: %s, Cluster: %s\n",
-		cm.SelectedContext, cm.RawConfig.Contexts[cm.SelectedContext].Cluster)
+	cm.contextsMu.Lock()
+	cm.contextClients = nil
+	cm.contextsMu.Unlock()
+
+	logger.Info("kubeconfig loaded",
+		"context", cm.SelectedContext, "cluster", cm.RawConfig.Contexts[cm.SelectedContext].Cluster)
 
 	return nil
 }
@@ -310,34 +348,126 @@ func (cm *ClientManager) getListerWatcher(ctx context.Context, resourceType stri
 	return nil, fmt.Errorf("unsupported resource type: %s", resourceType)
 }
 
-// WatchResources watches a specific resource type in a namespace
+// WatchResources watches a specific resource type in a namespace.
+// Events are dropped rather than blocking the informer when eventChan is full.
 func (cm *ClientManager) WatchResources(ctx context.Context, resourceType string, namespace string, labelSelector string, fieldSelector string, eventChan chan ResourceEvent) error {
+	return cm.watchResources(ctx, resourceType, namespace, labelSelector, fieldSelector, eventChan, true)
+}
+
+// WatchResourcesLossless is WatchResources for consumers that must see every
+// event: the informer waits for room in eventChan until ctx is done.
+func (cm *ClientManager) WatchResourcesLossless(ctx context.Context, resourceType string, namespace string, labelSelector string, fieldSelector string, eventChan chan ResourceEvent) error {
+	return cm.watchResources(ctx, resourceType, namespace, labelSelector, fieldSelector, eventChan, false)
+}
+
+func (cm *ClientManager) watchResources(ctx context.Context, resourceType string, namespace string, labelSelector string, fieldSelector string, eventChan chan ResourceEvent, lossy bool) error {
 	listWatch, err := cm.getListerWatcher(ctx, resourceType, namespace, labelSelector, fieldSelector)
 	if err != nil {
 		return err
 	}
 
+	send := func(event ResourceEvent) {
+		if !lossy {
+			select {
+			case eventChan <- event:
+			case <-ctx.Done():
+			}
+			return
+		}
+		select {
+		case eventChan <- event:
+		case <-ctx.Done():
+		default:
+			metrics.EventDropped(resourceType)
+		}
+	}
+
 	_, controller := cache.NewInformer(
 		listWatch,
 		nil, // object type is handled by the list/watch functions
 		0,   // resync period
 		cache.ResourceEventHandlerFuncs{
 			AddFunc: func(obj interface{}) {
-				eventChan <- ResourceEvent{Type: "ADDED", Object: obj}
+				send(ResourceEvent{Type: "ADDED", Object: obj})
 			},
 			UpdateFunc: func(oldObj, newObj interface{}) {
-				eventChan <- ResourceEvent{Type: "MODIFIED", Object: newObj}
+				send(ResourceEvent{Type: "MODIFIED", Object: newObj})
 			},
 			DeleteFunc: func(obj interface{}) {
-				eventChan <- ResourceEvent{Type: "DELETED", Object: obj}
+				send(ResourceEvent{Type: "DELETED", Object: obj})
 			},
 		},
 	)
 
-	go controller.Run(ctx.Done())
+	id := cm.registerInformer(resourceType, controller)
+	go func() {
+		defer metrics.TrackInformer()()
+		defer cm.unregisterInformer(id)
+		controller.Run(ctx.Done())
+	}()
 	return nil
 }
 
+// registeredInformer is a running informer and the resource type it watches
+type registeredInformer struct {
+	resource   string
+	controller cache.Controller
+}
+
+// InformerStatus counts the running informers of one resource type and how
+// many of them have completed their initial list
+type InformerStatus struct {
+	Running int `json:"running"`
+	Synced  int `json:"synced"`
+}
+
+func (cm *ClientManager) registerInformer(resourceType string, controller cache.Controller) int {
+	cm.informersMu.Lock()
+	defer cm.informersMu.Unlock()
+	if cm.informers == nil {
+		cm.informers = make(map[int]registeredInformer)
+	}
+	cm.informerSeq++
+	cm.informers[cm.informerSeq] = registeredInformer{resource: resourceType, controller: controller}
+	return cm.informerSeq
+}
+
+func (cm *ClientManager) unregisterInformer(id int) {
+	cm.informersMu.Lock()
+	defer cm.informersMu.Unlock()
+	delete(cm.informers, id)
+}
+
+// InformerSyncStatus returns the sync status of running informers by
+// resource type
+func (cm *ClientManager) InformerSyncStatus() map[string]InformerStatus {
+	cm.informersMu.Lock()
+	defer cm.informersMu.Unlock()
+	status := map[string]InformerStatus{}
+	for _, informer := range cm.informers {
+		s := status[informer.resource]
+		s.Running++
+		if informer.controller.HasSynced() {
+			s.Synced++
+		}
+		status[informer.resource] = s
+	}
+	return status
+}
+
+// ServerVersion returns the version reported by the API server
+func (cm *ClientManager) ServerVersion(ctx context.Context) (*version.Info, error) {
+	body, err := cm.Clientset.Discovery().RESTClient().Get().AbsPath("/version").Do(ctx).Raw()
+	if err != nil {
+		return nil, err
+	}
+	var info version.Info
+	if err := json.Unmarshal(body, &info); err != nil {
+		return nil, fmt.Errorf("unable to parse server version: %w", err)
+	}
+	return &info, nil
+}
+
 type APIResource struct {
 	Name       string   `json:"name"`
 	Kind       string   `json:"kind"`
@@ -346,12 +476,32 @@ type APIResource struct {
 	ShortNames []string `json:"shortNames"`
 }
 
-func (cm *ClientManager) GetAPIResources() ([]APIResource, error) {
+// discoveryClient returns a discovery client bounded by the deadline of ctx,
+// as discovery requests take no context. A deadline that has already passed
+// fails immediately rather than leaving the client without a timeout.
+func (cm *ClientManager) discoveryClient(ctx context.Context) (*discovery.DiscoveryClient, error) {
+	config := rest.CopyConfig(cm.Config)
+	if deadline, ok := ctx.Deadline(); ok {
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return nil, context.DeadlineExceeded
+		}
+		config.Timeout = remaining
+	}
+	return discovery.NewDiscoveryClientForConfig(config)
+}
+
+func (cm *ClientManager) GetAPIResources(ctx context.Context) ([]APIResource, error) {
 	if cm.Clientset == nil {
 		return nil, fmt.Errorf("clientset not initialized")
 	}
 
-	resourceLists, err := cm.Clientset.Discovery().ServerPreferredResources()
+	discoveryClient, err := cm.discoveryClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	resourceLists, err := discoveryClient.ServerPreferredResources()
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,59 @@
+package k8s
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/binodta/web-k9/backend/pkg/logging"
+	"github.com/binodta/web-k9/backend/pkg/metrics"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// contextClient is a REST config and matching HTTP transport for one context
+type contextClient struct {
+	config    *rest.Config
+	transport http.RoundTripper
+}
+
+// ContextTransport returns the REST config and an authenticated transport
+// for a context of the loaded kubeconfig, so requests can be made to a
+// cluster other than the selected one. Transports are cached until another
+// kubeconfig is loaded.
+func (cm *ClientManager) ContextTransport(name string) (*rest.Config, http.RoundTripper, error) {
+	if cm.RawConfig == nil {
+		return nil, nil, fmt.Errorf("kubeconfig not loaded")
+	}
+	if _, ok := cm.RawConfig.Contexts[name]; !ok {
+		return nil, nil, fmt.Errorf("context %q not found", name)
+	}
+
+	cm.contextsMu.Lock()
+	defer cm.contextsMu.Unlock()
+	if client, ok := cm.contextClients[name]; ok {
+		return client.config, client.transport, nil
+	}
+
+	var config *rest.Config
+	if name == cm.SelectedContext && cm.Config != nil {
+		config = cm.Config
+	} else {
+		var err error
+		config, err = clientcmd.NewNonInteractiveClientConfig(*cm.RawConfig, name, &clientcmd.ConfigOverrides{}, nil).ClientConfig()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get client config for context %q: %w", name, err)
+		}
+		config.Wrap(metrics.InstrumentTransport(name))
+		config.Wrap(logging.Transport(cm.Logger, name))
+	}
+
+	transport, err := rest.TransportFor(config)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create transport for context %q: %w", name, err)
+	}
+	if cm.contextClients == nil {
+		cm.contextClients = make(map[string]contextClient)
+	}
+	cm.contextClients[name] = contextClient{config: config, transport: transport}
+	return config, transport, nil
+}
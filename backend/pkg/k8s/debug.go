@@ -0,0 +1,94 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// imagePullFailures are waiting reasons that mean a container will not start
+// without intervention
+var imagePullFailures = map[string]bool{
+	"ErrImagePull":               true,
+	"ImagePullBackOff":           true,
+	"InvalidImageName":           true,
+	"ErrImageNeverPull":          true,
+	"CreateContainerError":       true,
+	"CreateContainerConfigError": true,
+}
+
+// AddDebugContainer adds an interactive ephemeral container running image to
+// a pod. When target is set the container joins that container's process
+// namespace. It returns the name of the new container.
+func (cm *ClientManager) AddDebugContainer(ctx context.Context, namespace string, podName string, target string, image string) (string, error) {
+	pod, err := cm.Clientset.CoreV1().Pods(namespace).Get(ctx, podName, metav1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+
+	name := "debugger-" + utilrand.String(5)
+	pod.Spec.EphemeralContainers = append(pod.Spec.EphemeralContainers, v1.EphemeralContainer{
+		EphemeralContainerCommon: v1.EphemeralContainerCommon{
+			Name:                     name,
+			Image:                    image,
+			ImagePullPolicy:          v1.PullIfNotPresent,
+			Stdin:                    true,
+			TTY:                      true,
+			TerminationMessagePolicy: v1.TerminationMessageReadFile,
+		},
+		TargetContainerName: target,
+	})
+
+	if _, err := cm.Clientset.CoreV1().Pods(namespace).UpdateEphemeralContainers(ctx, podName, pod, metav1.UpdateOptions{}); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// WaitForEphemeralContainer blocks until an ephemeral container is running.
+// It fails early if the container terminates or its image cannot be pulled.
+func (cm *ClientManager) WaitForEphemeralContainer(ctx context.Context, namespace string, podName string, container string) error {
+	w, err := cm.Clientset.CoreV1().Pods(namespace).Watch(ctx, metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("metadata.name", podName).String(),
+	})
+	if err != nil {
+		return err
+	}
+	defer w.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timed out waiting for container %s to start: %w", container, ctx.Err())
+		case event, ok := <-w.ResultChan():
+			if !ok {
+				return fmt.Errorf("watch closed before container %s started", container)
+			}
+			if event.Type == watch.Deleted {
+				return fmt.Errorf("pod %s was deleted", podName)
+			}
+			pod, ok := event.Object.(*v1.Pod)
+			if !ok {
+				continue
+			}
+			for _, status := range pod.Status.EphemeralContainerStatuses {
+				if status.Name != container {
+					continue
+				}
+				switch {
+				case status.State.Running != nil:
+					return nil
+				case status.State.Terminated != nil:
+					return fmt.Errorf("container %s terminated: %s", container, status.State.Terminated.Reason)
+				case status.State.Waiting != nil && imagePullFailures[status.State.Waiting.Reason]:
+					return fmt.Errorf("container %s cannot start: %s: %s", container, status.State.Waiting.Reason, status.State.Waiting.Message)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	mirrorPodAnnotation = "kubernetes.io/config.mirror"
+	// evictionRetryInterval is how long to wait before retrying an eviction
+	// refused by a PodDisruptionBudget
+	evictionRetryInterval = 5 * time.Second
+	// podDeletePollInterval is how often an evicted pod is checked for deletion
+	podDeletePollInterval = time.Second
+)
+
+// DrainOptions mirrors the kubectl drain flags
+type DrainOptions struct {
+	// IgnoreDaemonSets skips DaemonSet-managed pods instead of failing
+	IgnoreDaemonSets bool
+	// DeleteEmptyDirData allows evicting pods that use emptyDir volumes
+	DeleteEmptyDirData bool
+	// Force allows evicting pods without a controller
+	Force bool
+	// GracePeriodSeconds overrides the pods' termination grace period when
+	// not negative
+	GracePeriodSeconds int64
+	// Timeout bounds the whole drain; zero means no limit
+	Timeout time.Duration
+}
+
+// DrainEvent reports the progress of a drain. Type is one of cordoned,
+// skipped, evicting, waiting, evicted, failed or done.
+type DrainEvent struct {
+	Type      string `json:"type"`
+	Namespace string `json:"namespace,omitempty"`
+	Pod       string `json:"pod,omitempty"`
+	Message   string `json:"message,omitempty"`
+}
+
+// SetUnschedulable cordons or uncordons a node
+func (cm *ClientManager) SetUnschedulable(ctx context.Context, node string, unschedulable bool) error {
+	patch := fmt.Sprintf(`{"spec":{"unschedulable":%t}}`, unschedulable)
+	_, err := cm.Clientset.CoreV1().Nodes().Patch(ctx, node, types.StrategicMergePatchType, []byte(patch), metav1.PatchOptions{})
+	return err
+}
+
+// DrainNode cordons a node and evicts its pods through the Eviction API, so
+// PodDisruptionBudgets are respected; evictions they refuse are retried
+// until the timeout. Pods are checked up front as kubectl drain does, and
+// nothing is evicted if any pod cannot be. progress is called concurrently.
+func (cm *ClientManager) DrainNode(ctx context.Context, node string, opts DrainOptions, progress func(DrainEvent)) error {
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	if err := cm.SetUnschedulable(ctx, node, true); err != nil {
+		return err
+	}
+	progress(DrainEvent{Type: "cordoned", Message: fmt.Sprintf("node %s cordoned", node)})
+
+	list, err := cm.Clientset.CoreV1().Pods("").List(ctx, metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("spec.nodeName", node).String(),
+	})
+	if err != nil {
+		return err
+	}
+
+	var pods []v1.Pod
+	var problems []string
+	for _, pod := range list.Items {
+		skip, reason, err := drainFilter(&pod, opts)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s/%s: %v", pod.Namespace, pod.Name, err))
+			continue
+		}
+		if skip {
+			if reason != "" {
+				progress(DrainEvent{Type: "skipped", Namespace: pod.Namespace, Pod: pod.Name, Message: reason})
+			}
+			continue
+		}
+		pods = append(pods, pod)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("cannot drain node %s:\n%s", node, strings.Join(problems, "\n"))
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(pods))
+	for i := range pods {
+		wg.Add(1)
+		go func(pod *v1.Pod) {
+			defer wg.Done()
+			if err := cm.evictPod(ctx, pod, opts, progress); err != nil {
+				progress(DrainEvent{Type: "failed", Namespace: pod.Namespace, Pod: pod.Name, Message: err.Error()})
+				errs[i] = fmt.Errorf("%s/%s: %w", pod.Namespace, pod.Name, err)
+			}
+		}(&pods[i])
+	}
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	progress(DrainEvent{Type: "done", Message: fmt.Sprintf("node %s drained", node)})
+	return nil
+}
+
+// drainFilter decides whether a pod is evicted, skipped, or blocks the drain
+func drainFilter(pod *v1.Pod, opts DrainOptions) (skip bool, reason string, err error) {
+	if _, ok := pod.Annotations[mirrorPodAnnotation]; ok {
+		return true, "", nil
+	}
+	finished := pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed
+
+	controller := metav1.GetControllerOf(pod)
+	if controller != nil && controller.Kind == "DaemonSet" {
+		if !opts.IgnoreDaemonSets {
+			return false, "", errors.New("managed by a DaemonSet (use ignoreDaemonsets)")
+		}
+		return true, "ignoring DaemonSet-managed pod", nil
+	}
+	if controller == nil && !finished && !opts.Force {
+		return false, "", errors.New("not managed by a controller (use force)")
+	}
+	if !finished && !opts.DeleteEmptyDirData {
+		for _, volume := range pod.Spec.Volumes {
+			if volume.EmptyDir != nil {
+				return false, "", errors.New("uses emptyDir local storage (use deleteEmptyDirData)")
+			}
+		}
+	}
+	return false, "", nil
+}
+
+// evictPod evicts a pod, retrying while a PodDisruptionBudget refuses, and
+// waits until the pod is gone
+func (cm *ClientManager) evictPod(ctx context.Context, pod *v1.Pod, opts DrainOptions, progress func(DrainEvent)) error {
+	eviction := &policyv1.Eviction{
+		ObjectMeta: metav1.ObjectMeta{Name: pod.Name, Namespace: pod.Namespace},
+	}
+	if opts.GracePeriodSeconds >= 0 {
+		grace := opts.GracePeriodSeconds
+		eviction.DeleteOptions = &metav1.DeleteOptions{GracePeriodSeconds: &grace}
+	}
+
+	progress(DrainEvent{Type: "evicting", Namespace: pod.Namespace, Pod: pod.Name})
+	for {
+		err := cm.Clientset.PolicyV1().Evictions(pod.Namespace).Evict(ctx, eviction)
+		if err == nil || apierrors.IsNotFound(err) {
+			break
+		}
+		if !apierrors.IsTooManyRequests(err) {
+			return err
+		}
+		progress(DrainEvent{Type: "waiting", Namespace: pod.Namespace, Pod: pod.Name, Message: err.Error()})
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timed out waiting for disruption budget: %w", ctx.Err())
+		case <-time.After(evictionRetryInterval):
+		}
+	}
+
+	ticker := time.NewTicker(podDeletePollInterval)
+	defer ticker.Stop()
+	for {
+		current, err := cm.Clientset.CoreV1().Pods(pod.Namespace).Get(ctx, pod.Name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) || (err == nil && current.UID != pod.UID) {
+			progress(DrainEvent{Type: "evicted", Namespace: pod.Namespace, Pod: pod.Name})
+			return nil
+		}
+		if err != nil && ctx.Err() == nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timed out waiting for pod to terminate: %w", ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/util/retry"
+)
+
+// MetadataChange sets and removes keys of an object's labels or annotations
+type MetadataChange struct {
+	Set    map[string]string `json:"set"`
+	Remove []string          `json:"remove"`
+}
+
+// MetadataResult shows an object's labels or annotations around a change
+type MetadataResult struct {
+	Name      string            `json:"name"`
+	Namespace string            `json:"namespace,omitempty"`
+	Before    map[string]string `json:"before"`
+	After     map[string]string `json:"after"`
+	Error     string            `json:"error,omitempty"`
+}
+
+// Validate checks label or annotation keys and, for labels, values
+func (c MetadataChange) Validate(field string) error {
+	if len(c.Set) == 0 && len(c.Remove) == 0 {
+		return fmt.Errorf("no %s to set or remove", field)
+	}
+	for key, value := range c.Set {
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			return fmt.Errorf("invalid key %q: %s", key, strings.Join(errs, "; "))
+		}
+		if field == "labels" {
+			if errs := validation.IsValidLabelValue(value); len(errs) > 0 {
+				return fmt.Errorf("invalid value %q for %q: %s", value, key, strings.Join(errs, "; "))
+			}
+		}
+	}
+	for _, key := range c.Remove {
+		if _, ok := c.Set[key]; ok {
+			return fmt.Errorf("key %q is both set and removed", key)
+		}
+	}
+	return nil
+}
+
+// PatchMetadata applies a change to the labels or annotations of any
+// resource with a merge patch, so only the named keys are touched. The
+// patch carries the resourceVersion that was read, so Before is exact and
+// the change is retried if the object changed in between.
+func (cm *ClientManager) PatchMetadata(ctx context.Context, res ResolvedResource, namespace string, name string, field string, change MetadataChange) (MetadataResult, error) {
+	client := cm.ResourceInterface(res, namespace)
+	var result MetadataResult
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		obj, err := client.Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		result, err = cm.patchMetadata(ctx, res, obj, field, change)
+		return err
+	})
+	return result, err
+}
+
+// PatchMetadataBySelector applies a change to every object matching the
+// selectors. Failures are recorded per object.
+func (cm *ClientManager) PatchMetadataBySelector(ctx context.Context, res ResolvedResource, namespace string, labelSelector string, fieldSelector string, field string, change MetadataChange) ([]MetadataResult, error) {
+	list, err := cm.ResourceInterface(res, namespace).List(ctx, metav1.ListOptions{LabelSelector: labelSelector, FieldSelector: fieldSelector})
+	if err != nil {
+		return nil, err
+	}
+	results := []MetadataResult{}
+	for i := range list.Items {
+		item := &list.Items[i]
+		result, err := cm.PatchMetadata(ctx, res, item.GetNamespace(), item.GetName(), field, change)
+		if err != nil {
+			result = MetadataResult{
+				Name:      item.GetName(),
+				Namespace: item.GetNamespace(),
+				Before:    metadataField(item, field),
+				Error:     err.Error(),
+			}
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func (cm *ClientManager) patchMetadata(ctx context.Context, res ResolvedResource, obj *unstructured.Unstructured, field string, change MetadataChange) (MetadataResult, error) {
+	before := metadataField(obj, field)
+	result := MetadataResult{Name: obj.GetName(), Namespace: obj.GetNamespace(), Before: before}
+
+	// Keys mapped to nil are removed by the merge patch
+	values := map[string]interface{}{}
+	for key, value := range change.Set {
+		values[key] = value
+	}
+	for _, key := range change.Remove {
+		if _, ok := before[key]; ok {
+			values[key] = nil
+		}
+	}
+	if len(values) == 0 {
+		result.After = before
+		return result, nil
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"resourceVersion": obj.GetResourceVersion(),
+			field:             values,
+		},
+	})
+	if err != nil {
+		return result, err
+	}
+	updated, err := cm.ResourceInterface(res, obj.GetNamespace()).Patch(ctx, obj.GetName(), types.MergePatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		return result, err
+	}
+	result.After = metadataField(updated, field)
+	return result, nil
+}
+
+func metadataField(obj *unstructured.Unstructured, field string) map[string]string {
+	var values map[string]string
+	if field == "labels" {
+		values = obj.GetLabels()
+	} else {
+		values = obj.GetAnnotations()
+	}
+	if values == nil {
+		return nil
+	}
+	return maps.Clone(values)
+}
@@ -0,0 +1,225 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+)
+
+// NodeShellLabel marks pods created for node shells
+const NodeShellLabel = "webk9.io/node-shell"
+
+// nodeShellCommand enters every namespace of the host's init process and
+// starts a login shell, preferring bash
+var nodeShellCommand = []string{
+	"nsenter", "--target", "1", "--mount", "--uts", "--ipc", "--net", "--pid", "--",
+	"sh", "-c", "if command -v bash >/dev/null 2>&1; then exec bash -l; else exec sh -l; fi",
+}
+
+// NodeShellOptions configures the privileged pods used for node shells
+type NodeShellOptions struct {
+	Image       string
+	Namespace   string
+	Tolerations []v1.Toleration
+}
+
+// nodeShellRef remembers where a node shell pod was created, since the
+// active context may change while the session is open
+type nodeShellRef struct {
+	clientset kubernetes.Interface
+	namespace string
+	name      string
+}
+
+// CreateNodeShell starts a privileged pod pinned to node that shares the
+// host's PID, network and IPC namespaces. The pod is tracked until
+// DeleteNodeShell or CleanupNodeShells removes it.
+func (cm *ClientManager) CreateNodeShell(ctx context.Context, node string, opts NodeShellOptions) (*v1.Pod, error) {
+	// A truncated node name may end in a separator, which cannot precede another
+	name := fmt.Sprintf("node-shell-%s-%s", strings.TrimRight(truncate(node, 40), "-."), utilrand.String(5))
+	privileged := true
+	gracePeriod := int64(0)
+
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: opts.Namespace,
+			Labels: map[string]string{
+				NodeShellLabel:                 "true",
+				"app.kubernetes.io/managed-by": "webk9",
+			},
+		},
+		Spec: v1.PodSpec{
+			NodeName:                      node,
+			HostPID:                       true,
+			HostNetwork:                   true,
+			HostIPC:                       true,
+			RestartPolicy:                 v1.RestartPolicyNever,
+			TerminationGracePeriodSeconds: &gracePeriod,
+			Tolerations:                   opts.Tolerations,
+			Containers: []v1.Container{{
+				Name:            "shell",
+				Image:           opts.Image,
+				ImagePullPolicy: v1.PullIfNotPresent,
+				Command:         nodeShellCommand,
+				Stdin:           true,
+				StdinOnce:       true,
+				TTY:             true,
+				SecurityContext: &v1.SecurityContext{Privileged: &privileged},
+			}},
+		},
+	}
+
+	clientset := cm.Clientset
+	created, err := clientset.CoreV1().Pods(opts.Namespace).Create(ctx, pod, metav1.CreateOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	cm.nodeShellsMu.Lock()
+	if cm.nodeShells == nil {
+		cm.nodeShells = make(map[string]nodeShellRef)
+	}
+	cm.nodeShells[created.Namespace+"/"+created.Name] = nodeShellRef{clientset: clientset, namespace: created.Namespace, name: created.Name}
+	cm.nodeShellsMu.Unlock()
+
+	return created, nil
+}
+
+// DeleteNodeShell removes a node shell pod created by CreateNodeShell
+func (cm *ClientManager) DeleteNodeShell(ctx context.Context, namespace string, name string) error {
+	cm.nodeShellsMu.Lock()
+	ref, ok := cm.nodeShells[namespace+"/"+name]
+	delete(cm.nodeShells, namespace+"/"+name)
+	cm.nodeShellsMu.Unlock()
+	if !ok {
+		return nil
+	}
+	return ref.delete(ctx)
+}
+
+// CleanupNodeShells removes every node shell pod that is still running, for
+// use when the server shuts down
+func (cm *ClientManager) CleanupNodeShells(ctx context.Context) error {
+	cm.nodeShellsMu.Lock()
+	refs := cm.nodeShells
+	cm.nodeShells = nil
+	cm.nodeShellsMu.Unlock()
+
+	var errs []error
+	for _, ref := range refs {
+		if err := ref.delete(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (r nodeShellRef) delete(ctx context.Context) error {
+	gracePeriod := int64(0)
+	err := r.clientset.CoreV1().Pods(r.namespace).Delete(ctx, r.name, metav1.DeleteOptions{GracePeriodSeconds: &gracePeriod})
+	if err != nil {
+		return fmt.Errorf("failed to delete node shell pod %s/%s: %w", r.namespace, r.name, err)
+	}
+	return nil
+}
+
+// WaitForPodRunning blocks until a pod's containers are running. It fails
+// early if the pod terminates or an image cannot be pulled.
+func (cm *ClientManager) WaitForPodRunning(ctx context.Context, namespace string, podName string) error {
+	w, err := cm.Clientset.CoreV1().Pods(namespace).Watch(ctx, metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("metadata.name", podName).String(),
+	})
+	if err != nil {
+		return err
+	}
+	defer w.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timed out waiting for pod %s to start: %w", podName, ctx.Err())
+		case event, ok := <-w.ResultChan():
+			if !ok {
+				return fmt.Errorf("watch closed before pod %s started", podName)
+			}
+			if event.Type == watch.Deleted {
+				return fmt.Errorf("pod %s was deleted", podName)
+			}
+			pod, ok := event.Object.(*v1.Pod)
+			if !ok {
+				continue
+			}
+			switch pod.Status.Phase {
+			case v1.PodRunning:
+				return nil
+			case v1.PodSucceeded, v1.PodFailed:
+				return fmt.Errorf("pod %s exited: %s %s", podName, pod.Status.Reason, pod.Status.Message)
+			}
+			for _, status := range pod.Status.ContainerStatuses {
+				if status.State.Waiting != nil && imagePullFailures[status.State.Waiting.Reason] {
+					return fmt.Errorf("pod %s cannot start: %s: %s", podName, status.State.Waiting.Reason, status.State.Waiting.Message)
+				}
+			}
+		}
+	}
+}
+
+// ParseTolerations parses tolerations in kubectl taint syntax, separated by
+// commas: key=value:Effect, key:Effect, key, or * to tolerate every taint.
+// An empty spec also tolerates every taint.
+func ParseTolerations(spec string) ([]v1.Toleration, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" || spec == "*" {
+		return []v1.Toleration{{Operator: v1.TolerationOpExists}}, nil
+	}
+
+	var tolerations []v1.Toleration
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if item == "*" {
+			tolerations = append(tolerations, v1.Toleration{Operator: v1.TolerationOpExists})
+			continue
+		}
+
+		t := v1.Toleration{Operator: v1.TolerationOpExists}
+		keyValue, effect, hasEffect := strings.Cut(item, ":")
+		if hasEffect {
+			switch e := v1.TaintEffect(effect); e {
+			case v1.TaintEffectNoSchedule, v1.TaintEffectPreferNoSchedule, v1.TaintEffectNoExecute:
+				t.Effect = e
+			default:
+				return nil, fmt.Errorf("invalid toleration %q: unknown effect %q", item, effect)
+			}
+		}
+		key, value, hasValue := strings.Cut(keyValue, "=")
+		if key == "" {
+			return nil, fmt.Errorf("invalid toleration %q: missing key", item)
+		}
+		t.Key = key
+		if hasValue {
+			t.Operator = v1.TolerationOpEqual
+			t.Value = value
+		}
+		tolerations = append(tolerations, t)
+	}
+	return tolerations, nil
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
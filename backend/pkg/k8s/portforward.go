@@ -0,0 +1,170 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/portforward"
+	"k8s.io/client-go/transport/spdy"
+)
+
+// Errors returned by ResolvePodPort when a target exists but cannot be
+// forwarded to
+var (
+	// ErrPodUnavailable means no pod of the target is running or ready
+	ErrPodUnavailable = errors.New("no pod available")
+	// ErrPortNotFound means the target has no port of the given number or name
+	ErrPortNotFound = errors.New("port not found")
+)
+
+// ResolvePodPort resolves a port of a pod, service or workload to a ready
+// pod and the container port traffic should be sent to. Service ports are
+// matched by number or name and mapped through their target port; named
+// ports are looked up in the pod's containers.
+func ResolvePodPort(ctx context.Context, clientset kubernetes.Interface, namespace string, kind string, name string, port intstr.IntOrString) (string, int32, error) {
+	switch {
+	case IsPodKind(kind):
+		pod, err := clientset.CoreV1().Pods(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return "", 0, err
+		}
+		if pod.Status.Phase != v1.PodRunning {
+			return "", 0, fmt.Errorf("%w: pod %s is not running (phase %s)", ErrPodUnavailable, name, pod.Status.Phase)
+		}
+		podPort, err := containerPort(pod, port)
+		return pod.Name, podPort, err
+	case kind == "services" || kind == "service" || kind == "svc":
+		svc, err := clientset.CoreV1().Services(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return "", 0, err
+		}
+		svcPort, ok := findServicePort(svc, port)
+		if !ok {
+			return "", 0, fmt.Errorf("%w: service %s has no port %s", ErrPortNotFound, name, port.String())
+		}
+		if len(svc.Spec.Selector) == 0 {
+			return "", 0, fmt.Errorf("%w: service %s has no selector", ErrPodUnavailable, name)
+		}
+		pod, err := readyPod(ctx, clientset, namespace, metav1.FormatLabelSelector(&metav1.LabelSelector{MatchLabels: svc.Spec.Selector}))
+		if err != nil {
+			return "", 0, fmt.Errorf("service %s: %w", name, err)
+		}
+		target := svcPort.TargetPort
+		if target.Type == intstr.Int && target.IntVal == 0 {
+			target = intstr.FromInt32(svcPort.Port)
+		}
+		podPort, err := containerPort(pod, target)
+		return pod.Name, podPort, err
+	default:
+		selector, err := workloadSelector(ctx, clientset, namespace, kind, name)
+		if err != nil {
+			return "", 0, err
+		}
+		pod, err := readyPod(ctx, clientset, namespace, selector)
+		if err != nil {
+			return "", 0, fmt.Errorf("%s/%s: %w", kind, name, err)
+		}
+		podPort, err := containerPort(pod, port)
+		return pod.Name, podPort, err
+	}
+}
+
+// IsPodKind reports whether kind names pods rather than a service or workload
+func IsPodKind(kind string) bool {
+	return kind == "pods" || kind == "pod" || kind == "po"
+}
+
+// PortForwardDialer connects to the portforward subresource of a pod over
+// WebSockets, falling back to SPDY as kubectl does
+func PortForwardDialer(config *rest.Config, clientset kubernetes.Interface, namespace string, pod string) (httpstream.Dialer, error) {
+	u := clientset.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Namespace(namespace).
+		Name(pod).
+		SubResource("portforward").
+		URL()
+
+	transport, upgrader, err := spdy.RoundTripperFor(config)
+	if err != nil {
+		return nil, err
+	}
+	spdyDialer := spdy.NewDialer(upgrader, &http.Client{Transport: transport}, http.MethodPost, u)
+	websocketDialer, err := portforward.NewSPDYOverWebsocketDialer(u, config)
+	if err != nil {
+		return nil, err
+	}
+	return portforward.NewFallbackDialer(websocketDialer, spdyDialer, func(err error) bool {
+		return httpstream.IsUpgradeFailure(err) || httpstream.IsHTTPSProxyError(err)
+	}), nil
+}
+
+// IsPodReady reports whether a pod is running, ready and not being deleted
+func IsPodReady(pod *v1.Pod) bool {
+	if pod.DeletionTimestamp != nil || pod.Status.Phase != v1.PodRunning {
+		return false
+	}
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == v1.PodReady {
+			return cond.Status == v1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// readyPod returns a ready pod matching selector, picking the same pod for
+// the same set of candidates
+func readyPod(ctx context.Context, clientset kubernetes.Interface, namespace string, selector string) (*v1.Pod, error) {
+	list, err := clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(list.Items, func(a, b v1.Pod) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	for i := range list.Items {
+		if IsPodReady(&list.Items[i]) {
+			return &list.Items[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%w: no ready pods among %d matching %q", ErrPodUnavailable, len(list.Items), selector)
+}
+
+func findServicePort(svc *v1.Service, port intstr.IntOrString) (v1.ServicePort, bool) {
+	for _, p := range svc.Spec.Ports {
+		if port.Type == intstr.String && p.Name == port.StrVal {
+			return p, true
+		}
+		if port.Type == intstr.Int && p.Port == port.IntVal {
+			return p, true
+		}
+	}
+	return v1.ServicePort{}, false
+}
+
+// containerPort resolves a port number or container port name of a pod
+func containerPort(pod *v1.Pod, port intstr.IntOrString) (int32, error) {
+	if port.Type == intstr.Int {
+		if port.IntVal <= 0 || port.IntVal > 65535 {
+			return 0, fmt.Errorf("invalid port %d", port.IntVal)
+		}
+		return port.IntVal, nil
+	}
+	for _, container := range pod.Spec.Containers {
+		for _, p := range container.Ports {
+			if p.Name == port.StrVal {
+				return p.ContainerPort, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("%w: pod %s has no port named %q", ErrPortNotFound, pod.Name, port.StrVal)
+}
@@ -0,0 +1,123 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+)
+
+// discoveryTimeout bounds the discovery requests made to resolve resource
+// types, which cannot be cancelled through a context
+const discoveryTimeout = 30 * time.Second
+
+// resourceAliases are short names accepted by webk9 that the API server
+// does not publish
+var resourceAliases = map[string]string{
+	"sec": "secrets",
+}
+
+// ResolvedResource is a resource type resolved against the cluster's
+// discovery information
+type ResolvedResource struct {
+	GVR        schema.GroupVersionResource
+	Kind       string
+	Namespaced bool
+}
+
+// newDynamicClients creates the dynamic client and a discovery-backed REST
+// mapper that understands short names, as kubectl does
+func newDynamicClients(config *rest.Config) (dynamic.Interface, meta.RESTMapper, error) {
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create dynamic client: %w", err)
+	}
+	discoveryConfig := rest.CopyConfig(config)
+	discoveryConfig.Timeout = discoveryTimeout
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(discoveryConfig)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create discovery client: %w", err)
+	}
+	cached := memory.NewMemCacheClient(discoveryClient)
+	mapper := restmapper.NewShortcutExpander(restmapper.NewDeferredDiscoveryRESTMapper(cached), cached, nil)
+	return dynamicClient, mapper, nil
+}
+
+// ResolveResource maps a resource type in any form kubectl accepts -
+// plural, singular, kind, short name, or qualified as resource.group or
+// resource.version.group - to its preferred version. Discovery is cached and
+// refreshed when a type is not found, so newly installed CRDs resolve.
+// Unknown types fail with an error matched by meta.IsNoMatchError.
+func (cm *ClientManager) ResolveResource(ctx context.Context, resourceType string) (ResolvedResource, error) {
+	if cm.RESTMapper == nil {
+		return ResolvedResource{}, fmt.Errorf("kubeconfig not loaded")
+	}
+
+	// The REST mapper takes no context, so stop waiting for discovery once
+	// ctx is done; discoveryTimeout bounds the request left behind
+	type result struct {
+		res ResolvedResource
+		err error
+	}
+	resolved := make(chan result, 1)
+	go func() {
+		res, err := resolveResource(cm.RESTMapper, resourceType)
+		resolved <- result{res, err}
+	}()
+	select {
+	case r := <-resolved:
+		return r.res, r.err
+	case <-ctx.Done():
+		return ResolvedResource{}, ctx.Err()
+	}
+}
+
+func resolveResource(mapper meta.RESTMapper, resourceType string) (ResolvedResource, error) {
+	resourceType = strings.ToLower(resourceType)
+	if alias, ok := resourceAliases[resourceType]; ok {
+		resourceType = alias
+	}
+	fullySpecified, groupResource := schema.ParseResourceArg(resourceType)
+	var gvr schema.GroupVersionResource
+	var err error
+	if fullySpecified != nil {
+		gvr, err = mapper.ResourceFor(*fullySpecified)
+	}
+	if fullySpecified == nil || err != nil {
+		gvr, err = mapper.ResourceFor(groupResource.WithVersion(""))
+	}
+	if err != nil {
+		return ResolvedResource{}, fmt.Errorf("unknown resource type %q: %w", resourceType, err)
+	}
+
+	gvk, err := mapper.KindFor(gvr)
+	if err != nil {
+		return ResolvedResource{}, err
+	}
+	mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return ResolvedResource{}, err
+	}
+	return ResolvedResource{
+		GVR:        gvr,
+		Kind:       gvk.Kind,
+		Namespaced: mapping.Scope.Name() == meta.RESTScopeNameNamespace,
+	}, nil
+}
+
+// ResourceInterface returns a dynamic client for a resolved resource,
+// scoped to namespace when the resource is namespaced
+func (cm *ClientManager) ResourceInterface(res ResolvedResource, namespace string) dynamic.ResourceInterface {
+	if res.Namespaced {
+		return cm.DynamicClient.Resource(res.GVR).Namespace(namespace)
+	}
+	return cm.DynamicClient.Resource(res.GVR)
+}
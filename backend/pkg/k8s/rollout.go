@@ -0,0 +1,399 @@
+package k8s
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// deploymentsResource names deployments in API errors
+var deploymentsResource = appsv1.Resource("deployments")
+
+// Annotations used by rollouts, shared with kubectl
+const (
+	restartedAtAnnotation = "kubectl.kubernetes.io/restartedAt"
+	revisionAnnotation    = "deployment.kubernetes.io/revision"
+	changeCauseAnnotation = "kubernetes.io/change-cause"
+)
+
+// RolloutRevision is one entry of a workload's rollout history
+type RolloutRevision struct {
+	Revision    int64              `json:"revision"`
+	Name        string             `json:"name"`
+	ChangeCause string             `json:"changeCause,omitempty"`
+	CreatedAt   metav1.Time        `json:"createdAt"`
+	Current     bool               `json:"current"`
+	Template    v1.PodTemplateSpec `json:"template"`
+}
+
+// RolloutKind normalizes the kinds that support rollouts to their plural
+// resource name
+func RolloutKind(kind string) (string, error) {
+	switch kind {
+	case "deployments", "deployment", "deploy":
+		return "deployments", nil
+	case "statefulsets", "statefulset", "sts":
+		return "statefulsets", nil
+	case "daemonsets", "daemonset", "ds":
+		return "daemonsets", nil
+	}
+	return "", apierrors.NewBadRequest(fmt.Sprintf("rollouts are not supported for %s", kind))
+}
+
+// RolloutRestart restarts the pods of a workload by stamping its pod
+// template with the current time, as kubectl rollout restart does
+func (cm *ClientManager) RolloutRestart(ctx context.Context, namespace string, kind string, name string) error {
+	if kind == "deployments" {
+		dep, err := cm.Clientset.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if dep.Spec.Paused {
+			return apierrors.NewConflict(deploymentsResource, name, errors.New("can't restart a paused deployment (run rollout resume first)"))
+		}
+	}
+	patch := fmt.Sprintf(`{"spec":{"template":{"metadata":{"annotations":{%q:%q}}}}}`, restartedAtAnnotation, time.Now().Format(time.RFC3339))
+	return cm.patchWorkload(ctx, namespace, kind, name, types.StrategicMergePatchType, []byte(patch))
+}
+
+// SetDeploymentPaused pauses or resumes the rollout of a deployment
+func (cm *ClientManager) SetDeploymentPaused(ctx context.Context, namespace string, name string, paused bool) error {
+	patch := fmt.Sprintf(`{"spec":{"paused":%t}}`, paused)
+	_, err := cm.Clientset.AppsV1().Deployments(namespace).Patch(ctx, name, types.StrategicMergePatchType, []byte(patch), metav1.PatchOptions{})
+	return err
+}
+
+// RolloutHistory lists the revisions of a workload, oldest first. Deployment
+// revisions come from their ReplicaSets; statefulset and daemonset revisions
+// come from their ControllerRevisions.
+func (cm *ClientManager) RolloutHistory(ctx context.Context, namespace string, kind string, name string) ([]RolloutRevision, error) {
+	var revisions []RolloutRevision
+
+	switch kind {
+	case "deployments":
+		dep, err := cm.Clientset.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		selector, err := metav1.LabelSelectorAsSelector(dep.Spec.Selector)
+		if err != nil {
+			return nil, err
+		}
+		list, err := cm.Clientset.AppsV1().ReplicaSets(namespace).List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
+		if err != nil {
+			return nil, err
+		}
+		current := dep.Annotations[revisionAnnotation]
+		for i := range list.Items {
+			rs := &list.Items[i]
+			if !metav1.IsControlledBy(rs, dep) {
+				continue
+			}
+			revision, err := strconv.ParseInt(rs.Annotations[revisionAnnotation], 10, 64)
+			if err != nil {
+				continue
+			}
+			template := *rs.Spec.Template.DeepCopy()
+			delete(template.Labels, appsv1.DefaultDeploymentUniqueLabelKey)
+			revisions = append(revisions, RolloutRevision{
+				Revision:    revision,
+				Name:        rs.Name,
+				ChangeCause: rs.Annotations[changeCauseAnnotation],
+				CreatedAt:   rs.CreationTimestamp,
+				Current:     rs.Annotations[revisionAnnotation] == current,
+				Template:    template,
+			})
+		}
+	case "statefulsets", "daemonsets":
+		owner, selector, err := cm.revisionOwner(ctx, namespace, kind, name)
+		if err != nil {
+			return nil, err
+		}
+		list, err := cm.Clientset.AppsV1().ControllerRevisions(namespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+		if err != nil {
+			return nil, err
+		}
+		for i := range list.Items {
+			cr := &list.Items[i]
+			if !metav1.IsControlledBy(cr, owner) {
+				continue
+			}
+			var data struct {
+				Spec struct {
+					Template v1.PodTemplateSpec `json:"template"`
+				} `json:"spec"`
+			}
+			if err := json.Unmarshal(cr.Data.Raw, &data); err != nil {
+				return nil, fmt.Errorf("failed to decode revision %s: %w", cr.Name, err)
+			}
+			revisions = append(revisions, RolloutRevision{
+				Revision:    cr.Revision,
+				Name:        cr.Name,
+				ChangeCause: cr.Annotations[changeCauseAnnotation],
+				CreatedAt:   cr.CreationTimestamp,
+				Template:    data.Spec.Template,
+			})
+		}
+		slices.SortFunc(revisions, func(a, b RolloutRevision) int { return cmp.Compare(a.Revision, b.Revision) })
+		if sts, ok := owner.(*appsv1.StatefulSet); ok {
+			for i := range revisions {
+				revisions[i].Current = revisions[i].Name == sts.Status.UpdateRevision
+			}
+		} else if len(revisions) > 0 {
+			revisions[len(revisions)-1].Current = true
+		}
+		return revisions, nil
+	default:
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("rollouts are not supported for %s", kind))
+	}
+
+	slices.SortFunc(revisions, func(a, b RolloutRevision) int { return cmp.Compare(a.Revision, b.Revision) })
+	return revisions, nil
+}
+
+// RolloutUndo rolls a workload back to a revision of its history, or to the
+// revision before the current one when revision is 0. It returns the
+// revision rolled back to.
+func (cm *ClientManager) RolloutUndo(ctx context.Context, namespace string, kind string, name string, revision int64) (int64, error) {
+	history, err := cm.RolloutHistory(ctx, namespace, kind, name)
+	if err != nil {
+		return 0, err
+	}
+	target, err := undoTarget(history, kind, name, revision)
+	if err != nil {
+		return 0, err
+	}
+
+	switch kind {
+	case "deployments":
+		dep, err := cm.Clientset.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return 0, err
+		}
+		if dep.Spec.Paused {
+			return 0, apierrors.NewConflict(deploymentsResource, name, errors.New("can't roll back a paused deployment (run rollout resume first)"))
+		}
+		patch, err := json.Marshal([]map[string]interface{}{
+			{"op": "replace", "path": "/spec/template", "value": target.Template},
+		})
+		if err != nil {
+			return 0, err
+		}
+		if err := cm.patchWorkload(ctx, namespace, kind, name, types.JSONPatchType, patch); err != nil {
+			return 0, err
+		}
+	default:
+		// ControllerRevision data is itself a patch restoring the template
+		cr, err := cm.Clientset.AppsV1().ControllerRevisions(namespace).Get(ctx, target.Name, metav1.GetOptions{})
+		if err != nil {
+			return 0, err
+		}
+		if err := cm.patchWorkload(ctx, namespace, kind, name, types.StrategicMergePatchType, cr.Data.Raw); err != nil {
+			return 0, err
+		}
+	}
+	return target.Revision, nil
+}
+
+// undoTarget picks the revision of a workload to roll back to
+func undoTarget(history []RolloutRevision, kind string, name string, revision int64) (RolloutRevision, error) {
+	if revision > 0 {
+		for _, r := range history {
+			if r.Revision == revision {
+				if r.Current {
+					return r, apierrors.NewConflict(schema.GroupResource{Group: appsv1.GroupName, Resource: kind}, name, fmt.Errorf("revision %d is already the current revision", revision))
+				}
+				return r, nil
+			}
+		}
+		return RolloutRevision{}, revisionNotFound(fmt.Sprintf("revision %d not found", revision))
+	}
+
+	current := int64(-1)
+	for _, r := range history {
+		if r.Current {
+			current = r.Revision
+		}
+	}
+	for i := len(history) - 1; i >= 0; i-- {
+		if !history[i].Current && (current < 0 || history[i].Revision < current) {
+			return history[i], nil
+		}
+	}
+	return RolloutRevision{}, revisionNotFound("no previous revision to roll back to")
+}
+
+// revisionNotFound reports a rollout revision that does not exist
+func revisionNotFound(message string) error {
+	return &apierrors.StatusError{ErrStatus: metav1.Status{
+		Status:  metav1.StatusFailure,
+		Code:    http.StatusNotFound,
+		Reason:  metav1.StatusReasonNotFound,
+		Message: message,
+	}}
+}
+
+// revisionOwner fetches a statefulset or daemonset and its selector
+func (cm *ClientManager) revisionOwner(ctx context.Context, namespace string, kind string, name string) (metav1.Object, string, error) {
+	var owner metav1.Object
+	var selector *metav1.LabelSelector
+	switch kind {
+	case "statefulsets":
+		sts, err := cm.Clientset.AppsV1().StatefulSets(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, "", err
+		}
+		owner, selector = sts, sts.Spec.Selector
+	default:
+		ds, err := cm.Clientset.AppsV1().DaemonSets(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, "", err
+		}
+		owner, selector = ds, ds.Spec.Selector
+	}
+	s, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return nil, "", err
+	}
+	return owner, s.String(), nil
+}
+
+func (cm *ClientManager) patchWorkload(ctx context.Context, namespace string, kind string, name string, pt types.PatchType, patch []byte) error {
+	var err error
+	switch kind {
+	case "deployments":
+		_, err = cm.Clientset.AppsV1().Deployments(namespace).Patch(ctx, name, pt, patch, metav1.PatchOptions{})
+	case "statefulsets":
+		_, err = cm.Clientset.AppsV1().StatefulSets(namespace).Patch(ctx, name, pt, patch, metav1.PatchOptions{})
+	case "daemonsets":
+		_, err = cm.Clientset.AppsV1().DaemonSets(namespace).Patch(ctx, name, pt, patch, metav1.PatchOptions{})
+	default:
+		err = apierrors.NewBadRequest(fmt.Sprintf("rollouts are not supported for %s", kind))
+	}
+	return err
+}
+
+// GetWorkload returns a deployment, statefulset or daemonset
+func (cm *ClientManager) GetWorkload(ctx context.Context, namespace string, kind string, name string) (runtime.Object, error) {
+	switch kind {
+	case "deployments":
+		return cm.Clientset.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+	case "statefulsets":
+		return cm.Clientset.AppsV1().StatefulSets(namespace).Get(ctx, name, metav1.GetOptions{})
+	case "daemonsets":
+		return cm.Clientset.AppsV1().DaemonSets(namespace).Get(ctx, name, metav1.GetOptions{})
+	}
+	return nil, apierrors.NewBadRequest(fmt.Sprintf("rollouts are not supported for %s", kind))
+}
+
+// WatchWorkload watches a single deployment, statefulset or daemonset
+func (cm *ClientManager) WatchWorkload(ctx context.Context, namespace string, kind string, name string) (watch.Interface, error) {
+	opts := metav1.ListOptions{FieldSelector: fields.OneTermEqualSelector("metadata.name", name).String()}
+	switch kind {
+	case "deployments":
+		return cm.Clientset.AppsV1().Deployments(namespace).Watch(ctx, opts)
+	case "statefulsets":
+		return cm.Clientset.AppsV1().StatefulSets(namespace).Watch(ctx, opts)
+	case "daemonsets":
+		return cm.Clientset.AppsV1().DaemonSets(namespace).Watch(ctx, opts)
+	}
+	return nil, apierrors.NewBadRequest(fmt.Sprintf("rollouts are not supported for %s", kind))
+}
+
+// RolloutStatus describes the progress of a rollout with the same messages
+// as kubectl rollout status. done is true once the rollout is complete; an
+// error means it failed or cannot be followed.
+func RolloutStatus(obj runtime.Object) (message string, done bool, err error) {
+	switch o := obj.(type) {
+	case *appsv1.Deployment:
+		return deploymentStatus(o)
+	case *appsv1.StatefulSet:
+		return statefulSetStatus(o)
+	case *appsv1.DaemonSet:
+		return daemonSetStatus(o)
+	}
+	return "", false, fmt.Errorf("unsupported object %T", obj)
+}
+
+func deploymentStatus(d *appsv1.Deployment) (string, bool, error) {
+	if d.Generation > d.Status.ObservedGeneration {
+		return "Waiting for deployment spec update to be observed...", false, nil
+	}
+	for _, cond := range d.Status.Conditions {
+		if cond.Type == appsv1.DeploymentProgressing && cond.Reason == "ProgressDeadlineExceeded" {
+			return "", false, fmt.Errorf("deployment %q exceeded its progress deadline", d.Name)
+		}
+	}
+	replicas := int32(1)
+	if d.Spec.Replicas != nil {
+		replicas = *d.Spec.Replicas
+	}
+	if d.Status.UpdatedReplicas < replicas {
+		return fmt.Sprintf("Waiting for deployment %q rollout to finish: %d out of %d new replicas have been updated...", d.Name, d.Status.UpdatedReplicas, replicas), false, nil
+	}
+	if d.Status.Replicas > d.Status.UpdatedReplicas {
+		return fmt.Sprintf("Waiting for deployment %q rollout to finish: %d old replicas are pending termination...", d.Name, d.Status.Replicas-d.Status.UpdatedReplicas), false, nil
+	}
+	if d.Status.AvailableReplicas < d.Status.UpdatedReplicas {
+		return fmt.Sprintf("Waiting for deployment %q rollout to finish: %d of %d updated replicas are available...", d.Name, d.Status.AvailableReplicas, d.Status.UpdatedReplicas), false, nil
+	}
+	return fmt.Sprintf("deployment %q successfully rolled out", d.Name), true, nil
+}
+
+func statefulSetStatus(s *appsv1.StatefulSet) (string, bool, error) {
+	if s.Spec.UpdateStrategy.Type != appsv1.RollingUpdateStatefulSetStrategyType {
+		return "", false, fmt.Errorf("rollout status is only available for %s strategy type", appsv1.RollingUpdateStatefulSetStrategyType)
+	}
+	if s.Status.ObservedGeneration == 0 || s.Generation > s.Status.ObservedGeneration {
+		return "Waiting for statefulset spec update to be observed...", false, nil
+	}
+	replicas := int32(1)
+	if s.Spec.Replicas != nil {
+		replicas = *s.Spec.Replicas
+	}
+	if s.Status.ReadyReplicas < replicas {
+		return fmt.Sprintf("Waiting for %d pods to be ready...", replicas-s.Status.ReadyReplicas), false, nil
+	}
+	if ru := s.Spec.UpdateStrategy.RollingUpdate; ru != nil && ru.Partition != nil && *ru.Partition > 0 {
+		if s.Status.UpdatedReplicas < replicas-*ru.Partition {
+			return fmt.Sprintf("Waiting for partitioned roll out to finish: %d out of %d new pods have been updated...", s.Status.UpdatedReplicas, replicas-*ru.Partition), false, nil
+		}
+		return fmt.Sprintf("partitioned roll out complete: %d new pods have been updated...", s.Status.UpdatedReplicas), true, nil
+	}
+	if s.Status.UpdateRevision != s.Status.CurrentRevision {
+		return fmt.Sprintf("waiting for statefulset rolling update to complete %d pods at revision %s...", s.Status.UpdatedReplicas, s.Status.UpdateRevision), false, nil
+	}
+	return fmt.Sprintf("statefulset rolling update complete %d pods at revision %s...", s.Status.CurrentReplicas, s.Status.CurrentRevision), true, nil
+}
+
+func daemonSetStatus(d *appsv1.DaemonSet) (string, bool, error) {
+	if d.Spec.UpdateStrategy.Type != appsv1.RollingUpdateDaemonSetStrategyType {
+		return "", false, fmt.Errorf("rollout status is only available for %s strategy type", appsv1.RollingUpdateDaemonSetStrategyType)
+	}
+	if d.Generation > d.Status.ObservedGeneration {
+		return "Waiting for daemon set spec update to be observed...", false, nil
+	}
+	if d.Status.UpdatedNumberScheduled < d.Status.DesiredNumberScheduled {
+		return fmt.Sprintf("Waiting for daemon set %q rollout to finish: %d out of %d new pods have been updated...", d.Name, d.Status.UpdatedNumberScheduled, d.Status.DesiredNumberScheduled), false, nil
+	}
+	if d.Status.NumberAvailable < d.Status.DesiredNumberScheduled {
+		return fmt.Sprintf("Waiting for daemon set %q rollout to finish: %d of %d updated pods are available...", d.Name, d.Status.NumberAvailable, d.Status.DesiredNumberScheduled), false, nil
+	}
+	return fmt.Sprintf("daemon set %q successfully rolled out", d.Name), true, nil
+}
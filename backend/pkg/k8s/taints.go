@@ -0,0 +1,125 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+)
+
+// TaintChange adds or replaces taints, matched by key and effect, and removes
+// taints. A removal without an effect removes every taint with its key, like
+// kubectl taint node key-.
+type TaintChange struct {
+	Add    []v1.Taint `json:"add"`
+	Remove []v1.Taint `json:"remove"`
+}
+
+// TaintResult shows a node's taints around a change
+type TaintResult struct {
+	Name   string     `json:"name"`
+	Before []v1.Taint `json:"before"`
+	After  []v1.Taint `json:"after"`
+	Error  string     `json:"error,omitempty"`
+}
+
+// Validate checks that every taint has a key and a known effect
+func (c TaintChange) Validate() error {
+	if len(c.Add) == 0 && len(c.Remove) == 0 {
+		return fmt.Errorf("no taints to add or remove")
+	}
+	for _, t := range c.Add {
+		if t.Key == "" {
+			return fmt.Errorf("taint key is required")
+		}
+		switch t.Effect {
+		case v1.TaintEffectNoSchedule, v1.TaintEffectPreferNoSchedule, v1.TaintEffectNoExecute:
+		default:
+			return fmt.Errorf("invalid effect %q for taint %q", t.Effect, t.Key)
+		}
+	}
+	for _, t := range c.Remove {
+		if t.Key == "" {
+			return fmt.Errorf("taint key is required")
+		}
+	}
+	return nil
+}
+
+// PatchTaints applies a change to a node's taints. Taints are replaced as a
+// list, so the patch carries the resourceVersion it was computed from and is
+// retried if the node changed in between.
+func (cm *ClientManager) PatchTaints(ctx context.Context, name string, change TaintChange) (TaintResult, error) {
+	result := TaintResult{Name: name}
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		node, err := cm.Clientset.CoreV1().Nodes().Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		result, err = cm.patchTaints(ctx, node, change)
+		return err
+	})
+	return result, err
+}
+
+// PatchTaintsBySelector applies a change to every node matching
+// labelSelector. Failures are recorded per node.
+func (cm *ClientManager) PatchTaintsBySelector(ctx context.Context, labelSelector string, change TaintChange) ([]TaintResult, error) {
+	list, err := cm.Clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{LabelSelector: labelSelector})
+	if err != nil {
+		return nil, err
+	}
+	results := []TaintResult{}
+	for _, node := range list.Items {
+		result, err := cm.PatchTaints(ctx, node.Name, change)
+		if err != nil {
+			result = TaintResult{Name: node.Name, Before: node.Spec.Taints, Error: err.Error()}
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func (cm *ClientManager) patchTaints(ctx context.Context, node *v1.Node, change TaintChange) (TaintResult, error) {
+	result := TaintResult{Name: node.Name, Before: node.Spec.Taints}
+
+	taints := slices.DeleteFunc(slices.Clone(node.Spec.Taints), func(t v1.Taint) bool {
+		for _, r := range change.Remove {
+			if t.Key == r.Key && (r.Effect == "" || t.Effect == r.Effect) {
+				return true
+			}
+		}
+		for _, a := range change.Add {
+			if t.Key == a.Key && t.Effect == a.Effect {
+				return true
+			}
+		}
+		return false
+	})
+	for _, t := range change.Add {
+		if t.Effect == v1.TaintEffectNoExecute && t.TimeAdded == nil {
+			now := metav1.Now()
+			t.TimeAdded = &now
+		}
+		taints = append(taints, t)
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{"resourceVersion": node.ResourceVersion},
+		"spec":     map[string]interface{}{"taints": taints},
+	})
+	if err != nil {
+		return result, err
+	}
+	updated, err := cm.Clientset.CoreV1().Nodes().Patch(ctx, node.Name, types.StrategicMergePatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		return result, err
+	}
+	result.After = updated.Spec.Taints
+	return result, nil
+}
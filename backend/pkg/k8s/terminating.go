@@ -0,0 +1,193 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// remainingNamesLimit caps the object names reported per resource type
+	remainingNamesLimit = 20
+	// namespaceScanWorkers bounds concurrent lists while scanning a namespace
+	namespaceScanWorkers = 8
+)
+
+// NamespaceTermination explains why a namespace has not finished deleting
+type NamespaceTermination struct {
+	Name              string                  `json:"name"`
+	Phase             v1.NamespacePhase       `json:"phase"`
+	DeletionTimestamp *metav1.Time            `json:"deletionTimestamp,omitempty"`
+	Finalizers        []string                `json:"finalizers"`
+	SpecFinalizers    []v1.FinalizerName      `json:"specFinalizers"`
+	Conditions        []v1.NamespaceCondition `json:"conditions"`
+	Remaining         []RemainingResources    `json:"remaining"`
+	DiscoveryErrors   []string                `json:"discoveryErrors,omitempty"`
+}
+
+// RemainingResources are the objects of one type left in a namespace
+type RemainingResources struct {
+	Group      string   `json:"group,omitempty"`
+	Resource   string   `json:"resource"`
+	Count      int      `json:"count"`
+	Names      []string `json:"names"`
+	Finalizers []string `json:"finalizers,omitempty"`
+}
+
+// RemoveFinalizers clears the finalizers of an object stuck in Terminating so
+// that its deletion can complete, returning the finalizers that were
+// removed. Objects that are not being deleted are refused with a conflict,
+// as their finalizers still guard live state. For
+// namespaces the spec finalizers are cleared first, through the finalize
+// subresource. Clearing the last finalizer may complete the deletion, so an
+// object that is gone afterwards counts as success.
+func (cm *ClientManager) RemoveFinalizers(ctx context.Context, res ResolvedResource, namespace string, name string, dryRun bool) ([]string, error) {
+	client := cm.ResourceInterface(res, namespace)
+	obj, err := client.Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if obj.GetDeletionTimestamp() == nil {
+		return nil, apierrors.NewConflict(res.GVR.GroupResource(), name, errors.New("it is not being deleted"))
+	}
+	var dryRunOpt []string
+	if dryRun {
+		dryRunOpt = []string{metav1.DryRunAll}
+	}
+	removed := []string{}
+
+	if res.GVR.Group == "" && res.GVR.Resource == "namespaces" {
+		var ns v1.Namespace
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &ns); err != nil {
+			return nil, err
+		}
+		if len(ns.Spec.Finalizers) > 0 {
+			for _, f := range ns.Spec.Finalizers {
+				removed = append(removed, string(f))
+			}
+			ns.Spec.Finalizers = nil
+			_, err := cm.Clientset.CoreV1().Namespaces().Finalize(ctx, &ns, metav1.UpdateOptions{DryRun: dryRunOpt})
+			if apierrors.IsNotFound(err) {
+				return removed, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if finalizers := obj.GetFinalizers(); len(finalizers) > 0 {
+		patch := []byte(`{"metadata":{"finalizers":null}}`)
+		_, err := client.Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{DryRun: dryRunOpt})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return nil, err
+		}
+		removed = append(finalizers, removed...)
+	}
+	return removed, nil
+}
+
+// NamespaceTermination reports the finalizers, deletion conditions and
+// remaining objects of a namespace, to explain why it is stuck terminating
+func (cm *ClientManager) NamespaceTermination(ctx context.Context, name string) (*NamespaceTermination, error) {
+	ns, err := cm.Clientset.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	report := &NamespaceTermination{
+		Name:              ns.Name,
+		Phase:             ns.Status.Phase,
+		DeletionTimestamp: ns.DeletionTimestamp,
+		Finalizers:        ns.Finalizers,
+		SpecFinalizers:    ns.Spec.Finalizers,
+		Conditions:        ns.Status.Conditions,
+		Remaining:         []RemainingResources{},
+	}
+
+	discoveryClient, err := cm.discoveryClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// Partial results are still useful: unavailable API groups, such as a
+	// missing metrics server, are a common reason namespaces get stuck
+	lists, err := discoveryClient.ServerPreferredNamespacedResources()
+	if err != nil {
+		report.DiscoveryErrors = append(report.DiscoveryErrors, err.Error())
+	}
+
+	var gvrs []schema.GroupVersionResource
+	for _, list := range lists {
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil {
+			continue
+		}
+		for _, r := range list.APIResources {
+			if slices.Contains(r.Verbs, "list") {
+				gvrs = append(gvrs, gv.WithResource(r.Name))
+			}
+		}
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, namespaceScanWorkers)
+	for _, gvr := range gvrs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			items, err := cm.DynamicClient.Resource(gvr).Namespace(name).List(ctx, metav1.ListOptions{})
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				report.DiscoveryErrors = append(report.DiscoveryErrors, fmt.Sprintf("%s: %v", gvr.GroupResource(), err))
+				return
+			}
+			if len(items.Items) == 0 {
+				return
+			}
+			remaining := RemainingResources{Group: gvr.Group, Resource: gvr.Resource, Count: len(items.Items), Names: []string{}}
+			for _, item := range items.Items {
+				if len(remaining.Names) < remainingNamesLimit {
+					remaining.Names = append(remaining.Names, item.GetName())
+				}
+				for _, f := range item.GetFinalizers() {
+					if !slices.Contains(remaining.Finalizers, f) {
+						remaining.Finalizers = append(remaining.Finalizers, f)
+					}
+				}
+			}
+			report.Remaining = append(report.Remaining, remaining)
+		}()
+	}
+	wg.Wait()
+
+	slices.SortFunc(report.Remaining, func(a, b RemainingResources) int {
+		if a.Group != b.Group {
+			if a.Group < b.Group {
+				return -1
+			}
+			return 1
+		}
+		if a.Resource < b.Resource {
+			return -1
+		}
+		if a.Resource > b.Resource {
+			return 1
+		}
+		return 0
+	})
+	slices.Sort(report.DiscoveryErrors)
+	return report, nil
+}
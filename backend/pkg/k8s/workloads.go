@@ -0,0 +1,63 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// WorkloadSelector returns the pod label selector of a deployment,
+// statefulset, daemonset, replicaset or job
+func (cm *ClientManager) WorkloadSelector(ctx context.Context, namespace string, kind string, name string) (string, error) {
+	return workloadSelector(ctx, cm.Clientset, namespace, kind, name)
+}
+
+func workloadSelector(ctx context.Context, clientset kubernetes.Interface, namespace string, kind string, name string) (string, error) {
+	var selector *metav1.LabelSelector
+
+	switch kind {
+	case "deployments", "deploy":
+		obj, err := clientset.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return "", err
+		}
+		selector = obj.Spec.Selector
+	case "statefulsets", "sts":
+		obj, err := clientset.AppsV1().StatefulSets(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return "", err
+		}
+		selector = obj.Spec.Selector
+	case "daemonsets", "ds":
+		obj, err := clientset.AppsV1().DaemonSets(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return "", err
+		}
+		selector = obj.Spec.Selector
+	case "replicasets", "rs":
+		obj, err := clientset.AppsV1().ReplicaSets(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return "", err
+		}
+		selector = obj.Spec.Selector
+	case "jobs":
+		obj, err := clientset.BatchV1().Jobs(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return "", err
+		}
+		selector = obj.Spec.Selector
+	default:
+		return "", fmt.Errorf("unsupported workload kind: %s", kind)
+	}
+
+	s, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return "", fmt.Errorf("invalid selector on %s/%s: %w", kind, name, err)
+	}
+	if s.Empty() {
+		return "", fmt.Errorf("%s/%s has an empty selector", kind, name)
+	}
+	return s.String(), nil
+}
@@ -0,0 +1,134 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
+)
+
+// RequestIDKey is the Fiber locals key holding the request ID. Locals are
+// copied onto WebSocket connections, so the same key works for both.
+const RequestIDKey = "requestid"
+
+// sensitiveKeys are attribute key fragments whose values are never written
+var sensitiveKeys = []string{"token", "password", "secret", "authorization", "credential", "bearer", "cookie"}
+
+type requestIDContextKey struct{}
+
+// New builds a logger writing to w at the given level ("debug", "info",
+// "warn", "error") in the given format ("text" or "json")
+func New(w io.Writer, level string, format string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("invalid log level %q", level)
+	}
+
+	opts := &slog.HandlerOptions{Level: lvl, ReplaceAttr: redact}
+	switch format {
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	case "text", "":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	}
+	return nil, fmt.Errorf("invalid log format %q", format)
+}
+
+func redact(_ []string, a slog.Attr) slog.Attr {
+	key := strings.ToLower(a.Key)
+	for _, s := range sensitiveKeys {
+		if strings.Contains(key, s) {
+			return slog.String(a.Key, "[REDACTED]")
+		}
+	}
+	return a
+}
+
+// WithRequestID returns a copy of ctx carrying the request ID
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDContextKey{}, id)
+}
+
+// RequestID returns the request ID carried by ctx, if any
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDContextKey{}).(string)
+	return id
+}
+
+// Middleware assigns every request an ID, stores it on the request context
+// and logs the request once it has been handled
+func Middleware(logger *slog.Logger) []fiber.Handler {
+	return []fiber.Handler{
+		requestid.New(requestid.Config{ContextKey: RequestIDKey}),
+		func(c *fiber.Ctx) error {
+			id, _ := c.Locals(RequestIDKey).(string)
+			c.SetUserContext(WithRequestID(c.UserContext(), id))
+
+			start := time.Now()
+			err := c.Next()
+
+			status := c.Response().StatusCode()
+			if fe, ok := err.(*fiber.Error); ok {
+				status = fe.Code
+			}
+			level := slog.LevelInfo
+			if status >= fiber.StatusInternalServerError {
+				level = slog.LevelError
+			}
+			logger.LogAttrs(c.UserContext(), level, "http request",
+				slog.String("request_id", id),
+				slog.String("method", c.Method()),
+				slog.String("path", c.Path()),
+				slog.String("route", c.Route().Path),
+				slog.Int("status", status),
+				slog.Duration("latency", time.Since(start)),
+				slog.String("ip", c.IP()),
+			)
+			return err
+		},
+	}
+}
+
+// Transport returns a rest.Config transport wrapper that logs every
+// Kubernetes API call at debug level, tagged with the originating request ID.
+// Headers are never logged so bearer tokens and client credentials stay out
+// of the output.
+func Transport(logger *slog.Logger, contextName string) func(http.RoundTripper) http.RoundTripper {
+	return func(rt http.RoundTripper) http.RoundTripper {
+		return &loggingTransport{next: rt, logger: logger.With("context", contextName)}
+	}
+}
+
+type loggingTransport struct {
+	next   http.RoundTripper
+	logger *slog.Logger
+}
+
+func (t *loggingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	if !t.logger.Enabled(ctx, slog.LevelDebug) {
+		return t.next.RoundTrip(req)
+	}
+
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	attrs := []slog.Attr{
+		slog.String("request_id", RequestID(ctx)),
+		slog.String("method", req.Method),
+		slog.String("path", req.URL.Path),
+		slog.Duration("latency", time.Since(start)),
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	} else {
+		attrs = append(attrs, slog.Int("status", resp.StatusCode))
+	}
+	t.logger.LogAttrs(ctx, slog.LevelDebug, "kubernetes api call", attrs...)
+	return resp, err
+}
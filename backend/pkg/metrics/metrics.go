@@ -0,0 +1,174 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	clientmetrics "k8s.io/client-go/tools/metrics"
+)
+
+const namespace = "webk9"
+
+var (
+	registry = prometheus.NewRegistry()
+
+	httpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of HTTP requests handled by the server, partitioned by route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	websocketConnections = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "websocket_connections_active",
+		Help:      "Number of open WebSocket connections, partitioned by stream type.",
+	}, []string{"type"})
+
+	informersRunning = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "informers_running",
+		Help:      "Number of resource informers currently running.",
+	})
+
+	eventsDropped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "resource_events_dropped_total",
+		Help:      "Resource events dropped because the WebSocket client could not keep up.",
+	}, []string{"resource"})
+
+	kubeAPICalls = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "kube_api_calls_total",
+		Help:      "Kubernetes API calls, partitioned by kubeconfig context.",
+	}, []string{"context"})
+
+	kubeAPIErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "kube_api_errors_total",
+		Help:      "Kubernetes API calls that failed in transport or returned a 5xx status, partitioned by kubeconfig context.",
+	}, []string{"context"})
+
+	kubeRequestLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "kube_api_request_duration_seconds",
+		Help:      "Kubernetes API request latency reported by client-go, partitioned by verb and host.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"verb", "host"})
+
+	kubeRequestResults = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "kube_api_request_results_total",
+		Help:      "Kubernetes API responses reported by client-go, partitioned by status code, method and host.",
+	}, []string{"code", "method", "host"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequestDuration,
+		websocketConnections,
+		informersRunning,
+		eventsDropped,
+		kubeAPICalls,
+		kubeAPIErrors,
+		kubeRequestLatency,
+		kubeRequestResults,
+	)
+
+	clientmetrics.Register(clientmetrics.RegisterOpts{
+		RequestLatency: latencyAdapter{},
+		RequestResult:  resultAdapter{},
+	})
+}
+
+// Handler serves the registered metrics in the Prometheus exposition format
+func Handler() fiber.Handler {
+	return adaptor.HTTPHandler(promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+}
+
+// Middleware records the latency of every HTTP request by its matched route
+func Middleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		err := c.Next()
+
+		status := c.Response().StatusCode()
+		if fe, ok := err.(*fiber.Error); ok {
+			status = fe.Code
+		}
+		httpRequestDuration.WithLabelValues(c.Method(), c.Route().Path, strconv.Itoa(status)).
+			Observe(time.Since(start).Seconds())
+		return err
+	}
+}
+
+// TrackWebSocket marks a WebSocket connection of the given type as open.
+// The returned function must be called when the connection closes.
+func TrackWebSocket(streamType string) func() {
+	gauge := websocketConnections.WithLabelValues(streamType)
+	gauge.Inc()
+	return gauge.Dec
+}
+
+// TrackInformer marks an informer as running.
+// The returned function must be called when the informer stops.
+func TrackInformer() func() {
+	informersRunning.Inc()
+	return informersRunning.Dec
+}
+
+// EventDropped counts a resource event that could not be delivered to a client
+func EventDropped(resourceType string) {
+	eventsDropped.WithLabelValues(resourceType).Inc()
+}
+
+// InstrumentTransport returns a rest.Config transport wrapper that counts
+// API calls and errors for the given kubeconfig context
+func InstrumentTransport(contextName string) func(http.RoundTripper) http.RoundTripper {
+	return func(rt http.RoundTripper) http.RoundTripper {
+		return &instrumentedTransport{
+			next:   rt,
+			calls:  kubeAPICalls.WithLabelValues(contextName),
+			errors: kubeAPIErrors.WithLabelValues(contextName),
+		}
+	}
+}
+
+type instrumentedTransport struct {
+	next   http.RoundTripper
+	calls  prometheus.Counter
+	errors prometheus.Counter
+}
+
+func (t *instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.calls.Inc()
+	resp, err := t.next.RoundTrip(req)
+	if err != nil || resp.StatusCode >= http.StatusInternalServerError {
+		t.errors.Inc()
+	}
+	return resp, err
+}
+
+// latencyAdapter feeds client-go request latencies into Prometheus
+type latencyAdapter struct{}
+
+func (latencyAdapter) Observe(_ context.Context, verb string, u url.URL, latency time.Duration) {
+	kubeRequestLatency.WithLabelValues(verb, u.Host).Observe(latency.Seconds())
+}
+
+// resultAdapter feeds client-go response codes into Prometheus
+type resultAdapter struct{}
+
+func (resultAdapter) Increment(_ context.Context, code string, method string, host string) {
+	kubeRequestResults.WithLabelValues(code, method, host).Inc()
+}
@@ -0,0 +1,540 @@
+// Package portforward keeps port-forwards from the webk9 host to pods open
+// across pod restarts. Each forward owns a local listener; connections are
+// proxied to a client-go port-forward on an internal loopback port, which is
+// re-established against a new pod whenever the current one goes away.
+package portforward
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/binodta/web-k9/backend/pkg/k8s"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/portforward"
+)
+
+var (
+	// ErrNotFound is returned for forward IDs that do not exist
+	ErrNotFound = errors.New("port-forward not found")
+	// ErrInvalidRequest is returned for requests missing or misusing fields
+	ErrInvalidRequest = errors.New("invalid port-forward request")
+	// ErrPortInUse is returned when the local port is already taken
+	ErrPortInUse = errors.New("local port already in use")
+)
+
+// Forward states
+const (
+	StatusStarting     = "starting"
+	StatusActive       = "active"
+	StatusReconnecting = "reconnecting"
+	StatusStopped      = "stopped"
+)
+
+const (
+	// resolveTimeout bounds finding a pod for a forward
+	resolveTimeout = 30 * time.Second
+	// podCheckInterval is how often the forwarded pod is checked
+	podCheckInterval = 5 * time.Second
+	// maxBackoff caps the delay between reconnect attempts
+	maxBackoff = 30 * time.Second
+	// connectWait is how long a new connection waits for a reconnecting tunnel
+	connectWait = 10 * time.Second
+)
+
+// Request describes a port-forward to start
+type Request struct {
+	Namespace string `json:"namespace"`
+	// Kind is pods, services, or a workload kind such as deployments
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+	// Port is the remote port number or name; for services, a service port
+	Port intstr.IntOrString `json:"port"`
+	// LocalPort is the port to listen on; 0 picks a free port
+	LocalPort int `json:"localPort"`
+}
+
+// Info is a snapshot of a forward's state
+type Info struct {
+	ID            string    `json:"id"`
+	Context       string    `json:"context"`
+	Namespace     string    `json:"namespace"`
+	Kind          string    `json:"kind"`
+	Name          string    `json:"name"`
+	Port          string    `json:"port"`
+	Address       string    `json:"address"`
+	LocalPort     int       `json:"localPort"`
+	Status        string    `json:"status"`
+	Pod           string    `json:"pod,omitempty"`
+	PodPort       int32     `json:"podPort,omitempty"`
+	Error         string    `json:"error,omitempty"`
+	Reconnects    int       `json:"reconnects"`
+	Connections   int64     `json:"connections"`
+	BytesSent     int64     `json:"bytesSent"`
+	BytesReceived int64     `json:"bytesReceived"`
+	StartedAt     time.Time `json:"startedAt"`
+}
+
+// Manager tracks running port-forwards
+type Manager struct {
+	address string
+	logger  *slog.Logger
+
+	mu       sync.Mutex
+	forwards map[string]*forward
+}
+
+// NewManager returns a manager whose forwards listen on address
+func NewManager(address string, logger *slog.Logger) *Manager {
+	return &Manager{address: address, logger: logger, forwards: make(map[string]*forward)}
+}
+
+// Start resolves the target of req and starts forwarding to it. The
+// forward keeps using config and clientset if the selected context changes.
+func (m *Manager) Start(ctx context.Context, config *rest.Config, clientset kubernetes.Interface, contextName string, req Request) (Info, error) {
+	if req.Namespace == "" {
+		req.Namespace = "default"
+	}
+	if req.Kind == "" {
+		req.Kind = "pods"
+	}
+	if req.Name == "" {
+		return Info{}, fmt.Errorf("%w: name is required", ErrInvalidRequest)
+	}
+	if req.LocalPort < 0 || req.LocalPort > 65535 {
+		return Info{}, fmt.Errorf("%w: invalid local port %d", ErrInvalidRequest, req.LocalPort)
+	}
+
+	// Fail fast on targets that cannot be resolved at all
+	resolveCtx, cancel := context.WithTimeout(ctx, resolveTimeout)
+	pod, podPort, err := k8s.ResolvePodPort(resolveCtx, clientset, req.Namespace, req.Kind, req.Name, req.Port)
+	cancel()
+	if err != nil {
+		return Info{}, err
+	}
+
+	listener, err := net.Listen("tcp", net.JoinHostPort(m.address, strconv.Itoa(req.LocalPort)))
+	if errors.Is(err, syscall.EADDRINUSE) {
+		return Info{}, fmt.Errorf("%w: %v", ErrPortInUse, err)
+	}
+	if err != nil {
+		return Info{}, err
+	}
+
+	runCtx, runCancel := context.WithCancel(context.Background())
+	f := &forward{
+		id:        utilrand.String(8),
+		context:   contextName,
+		req:       req,
+		config:    config,
+		clientset: clientset,
+		listener:  listener,
+		ctx:       runCtx,
+		cancel:    runCancel,
+		startedAt: time.Now(),
+		status:    StatusStarting,
+		pod:       pod,
+		podPort:   podPort,
+	}
+	f.logger = m.logger.With("portforward", f.id, "namespace", req.Namespace, "kind", req.Kind, "name", req.Name)
+
+	m.mu.Lock()
+	m.forwards[f.id] = f
+	m.mu.Unlock()
+
+	go f.acceptLoop()
+	go f.run()
+
+	f.logger.Info("started port-forward", "address", listener.Addr().String(), "port", req.Port.String())
+	return f.info(), nil
+}
+
+// List returns every forward, oldest first
+func (m *Manager) List() []Info {
+	m.mu.Lock()
+	infos := make([]Info, 0, len(m.forwards))
+	for _, f := range m.forwards {
+		infos = append(infos, f.info())
+	}
+	m.mu.Unlock()
+
+	slices.SortFunc(infos, func(a, b Info) int {
+		return a.StartedAt.Compare(b.StartedAt)
+	})
+	return infos
+}
+
+// Get returns one forward
+func (m *Manager) Get(id string) (Info, error) {
+	m.mu.Lock()
+	f, ok := m.forwards[id]
+	m.mu.Unlock()
+	if !ok {
+		return Info{}, ErrNotFound
+	}
+	return f.info(), nil
+}
+
+// Stop closes a forward and its connections
+func (m *Manager) Stop(id string) (Info, error) {
+	m.mu.Lock()
+	f, ok := m.forwards[id]
+	delete(m.forwards, id)
+	m.mu.Unlock()
+	if !ok {
+		return Info{}, ErrNotFound
+	}
+	f.stop()
+	return f.info(), nil
+}
+
+// StopAll closes every forward, for use when the server shuts down
+func (m *Manager) StopAll() {
+	m.mu.Lock()
+	forwards := m.forwards
+	m.forwards = make(map[string]*forward)
+	m.mu.Unlock()
+
+	for _, f := range forwards {
+		f.stop()
+	}
+}
+
+// forward is one port-forward. run keeps a tunnel to a ready pod open,
+// while acceptLoop proxies local connections through the current tunnel.
+type forward struct {
+	id        string
+	context   string
+	req       Request
+	config    *rest.Config
+	clientset kubernetes.Interface
+	listener  net.Listener
+	ctx       context.Context
+	cancel    context.CancelFunc
+	logger    *slog.Logger
+	startedAt time.Time
+
+	mu         sync.Mutex
+	status     string
+	pod        string
+	podPort    int32
+	lastErr    string
+	reconnects int
+	backend    string
+	// backendReady is closed once backend is set, and replaced when it is cleared
+	backendReady chan struct{}
+
+	connections   atomic.Int64
+	bytesSent     atomic.Int64
+	bytesReceived atomic.Int64
+}
+
+func (f *forward) info() Info {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return Info{
+		ID:            f.id,
+		Context:       f.context,
+		Namespace:     f.req.Namespace,
+		Kind:          f.req.Kind,
+		Name:          f.req.Name,
+		Port:          f.req.Port.String(),
+		Address:       f.listener.Addr().(*net.TCPAddr).IP.String(),
+		LocalPort:     f.listener.Addr().(*net.TCPAddr).Port,
+		Status:        f.status,
+		Pod:           f.pod,
+		PodPort:       f.podPort,
+		Error:         f.lastErr,
+		Reconnects:    f.reconnects,
+		Connections:   f.connections.Load(),
+		BytesSent:     f.bytesSent.Load(),
+		BytesReceived: f.bytesReceived.Load(),
+		StartedAt:     f.startedAt,
+	}
+}
+
+func (f *forward) stop() {
+	f.cancel()
+	f.listener.Close()
+	f.mu.Lock()
+	f.status = StatusStopped
+	f.mu.Unlock()
+	f.logger.Info("stopped port-forward")
+}
+
+// run keeps a tunnel open until the forward is stopped, re-resolving the
+// target each time the tunnel is lost
+func (f *forward) run() {
+	backoff := time.Second
+	for first := true; f.ctx.Err() == nil; first = false {
+		pod, podPort := f.currentTarget()
+		if !first {
+			resolveCtx, cancel := context.WithTimeout(f.ctx, resolveTimeout)
+			var err error
+			pod, podPort, err = k8s.ResolvePodPort(resolveCtx, f.clientset, f.req.Namespace, f.req.Kind, f.req.Name, f.req.Port)
+			cancel()
+			if err != nil {
+				f.setError(err)
+				if !sleep(f.ctx, backoff) {
+					return
+				}
+				backoff = min(backoff*2, maxBackoff)
+				continue
+			}
+		}
+
+		start := time.Now()
+		err := f.tunnel(pod, podPort)
+		if f.ctx.Err() != nil {
+			return
+		}
+		f.logger.Info("port-forward lost, reconnecting", "pod", pod, "error", err)
+		f.setError(err)
+		f.mu.Lock()
+		f.reconnects++
+		f.mu.Unlock()
+
+		if time.Since(start) > maxBackoff {
+			backoff = time.Second
+		}
+		if !sleep(f.ctx, backoff) {
+			return
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+// tunnel runs a client-go port-forward to pod on an internal loopback port
+// until the connection is lost, the pod stops being ready or the forward is
+// stopped
+func (f *forward) tunnel(pod string, podPort int32) error {
+	dialer, err := k8s.PortForwardDialer(f.config, f.clientset, f.req.Namespace, pod)
+	if err != nil {
+		return err
+	}
+
+	stopCh := make(chan struct{})
+	readyCh := make(chan struct{})
+	pf, err := portforward.NewOnAddresses(dialer, []string{"127.0.0.1"}, []string{fmt.Sprintf("0:%d", podPort)}, stopCh, readyCh, io.Discard, io.Discard)
+	if err != nil {
+		return err
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- pf.ForwardPorts()
+	}()
+	defer close(stopCh)
+
+	select {
+	case <-readyCh:
+	case err := <-errCh:
+		if err == nil {
+			err = errors.New("port-forward closed before it was ready")
+		}
+		return err
+	case <-f.ctx.Done():
+		return nil
+	}
+
+	ports, err := pf.GetPorts()
+	if err != nil || len(ports) == 0 {
+		return fmt.Errorf("port-forward has no local port: %v", err)
+	}
+	f.setActive(pod, podPort, net.JoinHostPort("127.0.0.1", strconv.Itoa(int(ports[0].Local))))
+	defer f.clearBackend()
+
+	podCtx, podCancel := context.WithCancel(f.ctx)
+	defer podCancel()
+	podGone := make(chan error, 1)
+	go func() {
+		podGone <- f.watchPod(podCtx, pod)
+	}()
+
+	select {
+	case err := <-errCh:
+		if err == nil {
+			err = portforward.ErrLostConnectionToPod
+		}
+		return err
+	case err := <-podGone:
+		return err
+	case <-f.ctx.Done():
+		return nil
+	}
+}
+
+// watchPod returns once pod is deleted or no longer ready. Pods forwarded to
+// by name only need to be running, as when the forward was started.
+func (f *forward) watchPod(ctx context.Context, pod string) error {
+	ticker := time.NewTicker(podCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+		obj, err := f.clientset.CoreV1().Pods(f.req.Namespace).Get(ctx, pod, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return fmt.Errorf("pod %s was deleted", pod)
+		}
+		if err != nil {
+			// Transient API errors leave the tunnel to fail on its own
+			continue
+		}
+		if k8s.IsPodKind(f.req.Kind) {
+			if obj.Status.Phase != v1.PodRunning {
+				return fmt.Errorf("pod %s is no longer running (phase %s)", pod, obj.Status.Phase)
+			}
+		} else if !k8s.IsPodReady(obj) {
+			return fmt.Errorf("pod %s is no longer ready", pod)
+		}
+	}
+}
+
+func (f *forward) acceptLoop() {
+	for {
+		conn, err := f.listener.Accept()
+		if err != nil {
+			return
+		}
+		go f.handle(conn)
+	}
+}
+
+// handle proxies one local connection through the tunnel, waiting briefly
+// for the tunnel if it is being re-established
+func (f *forward) handle(conn net.Conn) {
+	defer conn.Close()
+
+	backend := f.waitBackend()
+	if backend == "" {
+		return
+	}
+	upstream, err := net.Dial("tcp", backend)
+	if err != nil {
+		f.logger.Debug("failed to connect to tunnel", "error", err)
+		return
+	}
+	defer upstream.Close()
+
+	f.connections.Add(1)
+	defer f.connections.Add(-1)
+
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(upstream, &countingReader{r: conn, n: &f.bytesSent})
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(conn, &countingReader{r: upstream, n: &f.bytesReceived})
+		done <- struct{}{}
+	}()
+
+	select {
+	case <-done:
+	case <-f.ctx.Done():
+	}
+}
+
+func (f *forward) waitBackend() string {
+	ctx, cancel := context.WithTimeout(f.ctx, connectWait)
+	defer cancel()
+	for {
+		f.mu.Lock()
+		backend, ready := f.backend, f.backendReady
+		if ready == nil {
+			ready = make(chan struct{})
+			f.backendReady = ready
+		}
+		f.mu.Unlock()
+		if backend != "" {
+			return backend
+		}
+		select {
+		case <-ready:
+		case <-ctx.Done():
+			return ""
+		}
+	}
+}
+
+func (f *forward) currentTarget() (string, int32) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.pod, f.podPort
+}
+
+func (f *forward) setActive(pod string, podPort int32, backend string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.status == StatusStopped {
+		return
+	}
+	f.status = StatusActive
+	f.pod = pod
+	f.podPort = podPort
+	f.lastErr = ""
+	f.backend = backend
+	if f.backendReady != nil {
+		close(f.backendReady)
+		f.backendReady = nil
+	}
+}
+
+func (f *forward) clearBackend() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.backend = ""
+}
+
+func (f *forward) setError(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.status != StatusStopped {
+		f.status = StatusReconnecting
+	}
+	if err != nil {
+		f.lastErr = err.Error()
+	}
+}
+
+// sleep waits for d, returning false if ctx is cancelled first
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// countingReader adds the bytes read through it to n
+type countingReader struct {
+	r io.Reader
+	n *atomic.Int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n.Add(int64(n))
+	return n, err
+}
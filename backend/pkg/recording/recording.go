@@ -0,0 +1,347 @@
+// Package recording stores terminal sessions in the asciinema v2 format
+// (https://docs.asciinema.org/manual/asciicast/v2/), with a JSON metadata
+// file next to each recording.
+package recording
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+)
+
+// ErrNotFound is returned for recording IDs that do not exist
+var ErrNotFound = errors.New("recording not found")
+
+// validID matches the IDs generated by Start, so IDs taken from requests
+// cannot escape the recordings directory
+var validID = regexp.MustCompile(`^[0-9A-Za-z-]+$`)
+
+// Metadata describes a recorded session
+type Metadata struct {
+	ID         string     `json:"id"`
+	User       string     `json:"user,omitempty"`
+	RemoteAddr string     `json:"remoteAddr,omitempty"`
+	Context    string     `json:"context,omitempty"`
+	Namespace  string     `json:"namespace"`
+	Pod        string     `json:"pod"`
+	Container  string     `json:"container,omitempty"`
+	Command    []string   `json:"command,omitempty"`
+	StartedAt  time.Time  `json:"startedAt"`
+	EndedAt    *time.Time `json:"endedAt,omitempty"`
+	Size       int64      `json:"size"`
+}
+
+// header is the first line of an asciicast v2 file
+type header struct {
+	Version   int               `json:"version"`
+	Width     uint16            `json:"width"`
+	Height    uint16            `json:"height"`
+	Timestamp int64             `json:"timestamp"`
+	Title     string            `json:"title,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+// Store keeps recordings in a directory
+type Store struct {
+	dir string
+}
+
+// NewStore returns a store for dir, creating the directory if needed
+func NewStore(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create recordings directory: %w", err)
+	}
+	return &Store{dir: dir}, nil
+}
+
+// Start creates a recording for a session whose terminal is initially
+// width by height. meta.ID and meta.StartedAt are filled in. The cast header
+// is written by Begin, or by Close with meta.Command.
+func (s *Store) Start(meta Metadata, width uint16, height uint16) (*Recording, error) {
+	now := time.Now()
+	meta.ID = fmt.Sprintf("%s-%s", now.UTC().Format("20060102T150405Z"), utilrand.String(6))
+	meta.StartedAt = now
+
+	f, err := os.OpenFile(s.castPath(meta.ID), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o640)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Recording{store: s, file: f, start: now, meta: meta, width: width, height: height}
+	// Written up front so a session cut short by a crash is still attributed
+	if err := s.writeMetadata(meta); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// List returns the metadata of every recording, newest first
+func (s *Store) List() ([]Metadata, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	recordings := []Metadata{}
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok || !validID.MatchString(id) {
+			continue
+		}
+		meta, err := s.Get(id)
+		if err != nil {
+			continue
+		}
+		recordings = append(recordings, meta)
+	}
+	slices.SortFunc(recordings, func(a, b Metadata) int {
+		return b.StartedAt.Compare(a.StartedAt)
+	})
+	return recordings, nil
+}
+
+// Get returns the metadata of a recording
+func (s *Store) Get(id string) (Metadata, error) {
+	var meta Metadata
+	if !validID.MatchString(id) {
+		return meta, ErrNotFound
+	}
+	data, err := os.ReadFile(s.metadataPath(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return meta, ErrNotFound
+	}
+	if err != nil {
+		return meta, err
+	}
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return meta, err
+	}
+	if info, err := os.Stat(s.castPath(id)); err == nil {
+		meta.Size = info.Size()
+	}
+	return meta, nil
+}
+
+// Open returns the .cast file of a recording
+func (s *Store) Open(id string) (*os.File, error) {
+	if !validID.MatchString(id) {
+		return nil, ErrNotFound
+	}
+	f, err := os.Open(s.castPath(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
+
+func (s *Store) castPath(id string) string {
+	return filepath.Join(s.dir, id+".cast")
+}
+
+func (s *Store) metadataPath(id string) string {
+	return filepath.Join(s.dir, id+".json")
+}
+
+func (s *Store) writeMetadata(meta Metadata) error {
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.metadataPath(meta.ID), data, 0o640)
+}
+
+// Recording appends events to an open recording. It is safe for concurrent
+// use, and a nil *Recording discards everything.
+type Recording struct {
+	store *Store
+	start time.Time
+
+	mu   sync.Mutex
+	file *os.File
+	meta Metadata
+	err  error
+	// partial holds, per event code, the start of a UTF-8 sequence split
+	// across writes, so it is recorded whole with the next write
+	partial map[string][]byte
+
+	// The header names the command, so events are held back until Begin
+	// says which command actually started
+	width, height uint16
+	begun         bool
+	pending       [][]byte
+	pendingSize   int
+}
+
+// pendingLimit caps the events held back before Begin; past it the header
+// is written with the command given to Start
+const pendingLimit = 1 << 20
+
+// Begin writes the recording header for the command that was started,
+// followed by any events recorded so far. Calls after the first are ignored.
+func (r *Recording) Begin(command []string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.begin(command)
+}
+
+// begin implements Begin; r.mu must be held
+func (r *Recording) begin(command []string) {
+	if r.begun || r.file == nil {
+		return
+	}
+	r.begun = true
+	if command != nil {
+		r.meta.Command = command
+	}
+
+	hdr := header{
+		Version:   2,
+		Width:     r.width,
+		Height:    r.height,
+		Timestamp: r.start.Unix(),
+		Title:     fmt.Sprintf("%s/%s %s", r.meta.Namespace, r.meta.Pod, strings.Join(r.meta.Command, " ")),
+		Env:       map[string]string{"TERM": "xterm-256color"},
+	}
+	line, err := json.Marshal(hdr)
+	if err == nil {
+		r.write(line)
+	} else {
+		r.err = err
+	}
+	for _, line := range r.pending {
+		r.write(line)
+	}
+	r.pending, r.pendingSize = nil, 0
+	if r.err == nil {
+		r.err = r.store.writeMetadata(r.meta)
+	}
+}
+
+// write appends a line to the cast file; r.mu must be held
+func (r *Recording) write(line []byte) {
+	if r.err != nil {
+		return
+	}
+	_, r.err = r.file.Write(append(line, '\n'))
+}
+
+// ID returns the recording ID
+func (r *Recording) ID() string {
+	if r == nil {
+		return ""
+	}
+	return r.meta.ID
+}
+
+// Output records data written to the terminal
+func (r *Recording) Output(p []byte) {
+	r.stream("o", p)
+}
+
+// Input records data typed by the user
+func (r *Recording) Input(p []byte) {
+	r.stream("i", p)
+}
+
+// Resize records a terminal size change
+func (r *Recording) Resize(width uint16, height uint16) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.event("r", fmt.Sprintf("%dx%d", width, height))
+}
+
+// stream records a chunk of terminal data. Event data must be valid UTF-8,
+// so an incomplete sequence at the end of p is held back until the next chunk.
+func (r *Recording) stream(code string, p []byte) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	data := append(r.partial[code], p...)
+	n := len(data) - incompleteUTF8(data)
+	if r.partial == nil {
+		r.partial = map[string][]byte{}
+	}
+	r.partial[code] = slices.Clone(data[n:])
+	if n > 0 {
+		r.event(code, string(data[:n]))
+	}
+}
+
+// incompleteUTF8 returns the length of a truncated UTF-8 sequence at the
+// end of p
+func incompleteUTF8(p []byte) int {
+	for i := 1; i < utf8.UTFMax && i <= len(p); i++ {
+		if utf8.RuneStart(p[len(p)-i]) {
+			if utf8.FullRune(p[len(p)-i:]) {
+				return 0
+			}
+			return i
+		}
+	}
+	return 0
+}
+
+// event appends one [time, code, data] line; r.mu must be held. Write
+// errors stop the recording and are reported by Close.
+func (r *Recording) event(code string, data string) {
+	if r.file == nil || r.err != nil {
+		return
+	}
+	line, err := json.Marshal([]any{time.Since(r.start).Seconds(), code, data})
+	if err != nil {
+		r.err = err
+		return
+	}
+	if !r.begun {
+		r.pending = append(r.pending, line)
+		r.pendingSize += len(line)
+		if r.pendingSize > pendingLimit {
+			r.begin(nil)
+		}
+		return
+	}
+	r.write(line)
+}
+
+// Close finishes the recording and records when the session ended
+func (r *Recording) Close() error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return r.err
+	}
+	// Held-back bytes never completed a character, so record them as they are
+	for _, code := range []string{"i", "o"} {
+		if len(r.partial[code]) > 0 {
+			r.event(code, string(r.partial[code]))
+		}
+	}
+	r.begin(nil)
+	closeErr := r.file.Close()
+	r.file = nil
+
+	ended := time.Now()
+	r.meta.EndedAt = &ended
+	return errors.Join(r.err, closeErr, r.store.writeMetadata(r.meta))
+}
@@ -42,8 +42,15 @@ export const ShellView = ({ pod, namespace, container, onClose }: ShellViewProps
         wsRef.current = socket
 
         const startTime = Date.now()
+        const sendResize = () => {
+            if (socket.readyState === WebSocket.OPEN) {
+                socket.send(JSON.stringify({ type: 'resize', cols: term.cols, rows: term.rows }))
+            }
+        }
+
         socket.onopen = () => {
             term.writeln('\x1b[1;34mConnected to pod ' + pod + '\x1b[0m')
+            sendResize()
         }
 
         socket.onmessage = (event) => {
@@ -60,9 +67,10 @@ export const ShellView = ({ pod, namespace, container, onClose }: ShellViewProps
 
         term.onData((data) => {
             if (socket.readyState === WebSocket.OPEN) {
-                socket.send(data)
+                socket.send(JSON.stringify({ type: 'stdin', data }))
             }
         })
+        term.onResize(sendResize)
 
         const handleResize = () => fitAddon.fit()
         window.addEventListener('resize', handleResize)